@@ -1,10 +1,16 @@
 package gvm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/andrewkroh/gvm/common"
 )
 
 // GoRelease represents a Go release from the go.dev API
@@ -25,35 +31,219 @@ type GoFile struct {
 	Kind     string `json:"kind"`
 }
 
-// fetchGoReleases fetches the list of Go releases from the go.dev API
-func (m *Manager) fetchGoReleases() ([]GoRelease, error) {
+// localIndexFile is the name of the release index in a local directory mirror.
+const localIndexFile = "index.json"
+
+// releaseIndexInfo is the metadata stored alongside the cached release index.
+type releaseIndexInfo struct {
+	URL          string    // URL the index was fetched from.
+	ETag         string    // ETag response header.
+	LastModified string    // Last-Modified response header.
+	Validated    time.Time // Last time the index was fetched or revalidated.
+}
+
+func (m *Manager) releaseIndexFile() string {
+	return filepath.Join(m.cacheDir, "releases.json")
+}
+
+func (m *Manager) releaseIndexMetaFile() string {
+	return filepath.Join(m.cacheDir, "releases.meta")
+}
+
+// fetchGoReleases fetches the list of Go releases from the go.dev API. The
+// index is cached on disk and reused for ReleaseIndexTTL. After that it is
+// revalidated with a conditional request. If the request fails, or the Manager
+// is offline, the cached copy is used.
+func (m *Manager) fetchGoReleases(ctx context.Context) ([]GoRelease, error) {
+	if releases := m.loadedReleases(); releases != nil {
+		return releases, nil
+	}
+
+	// Concurrent callers share one fetch of the index.
+	return shareFlight(ctx, &m.flights, "releases", m.loadGoReleases)
+}
+
+// loadedReleases returns the release index held in memory if it is younger
+// than ReleaseIndexTTL.
+func (m *Manager) loadedReleases() []GoRelease {
+	m.releasesMu.Lock()
+	defer m.releasesMu.Unlock()
+	if m.releases != nil && time.Since(m.releasesLoaded) < m.ReleaseIndexTTL {
+		return m.releases
+	}
+	return nil
+}
+
+// loadGoReleases loads the release index from the cache or a mirror.
+func (m *Manager) loadGoReleases(ctx context.Context) ([]GoRelease, error) {
+	cached, info := m.readCachedReleases()
+	var releases []GoRelease
+	err := m.eachMirror(ctx, func(base string) error {
+		if dir, ok := common.FileURLPath(base); ok {
+			var err error
+			releases, err = readLocalReleases(dir)
+			return err
+		}
+
+		apiURL := releaseIndexURL(base)
+		var mirrorCached []GoRelease
+		if info.URL == apiURL {
+			mirrorCached = cached
+		}
+		if m.Offline {
+			if mirrorCached == nil {
+				return fmt.Errorf("release index is not cached: %w", ErrOffline)
+			}
+			releases = mirrorCached
+			return nil
+		}
+		if mirrorCached != nil && time.Since(info.Validated) < m.ReleaseIndexTTL {
+			m.Logger.Debug("Using cached release index.")
+			releases = mirrorCached
+			return nil
+		}
+
+		var err error
+		releases, err = m.downloadGoReleases(ctx, apiURL, mirrorCached, info)
+		return err
+	})
+	if err != nil {
+		if cached == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		m.Logger.WithError(err).Warnf("Failed to update release index. Using cached copy from %v.", info.Validated.Format(time.RFC3339))
+		releases = cached
+	}
+
+	m.setReleases(releases)
+	return releases, nil
+}
+
+// releaseIndexURL returns the URL of the release index of a mirror.
+func releaseIndexURL(base string) string {
 	// Ensure the base URL has a trailing slash before adding query parameters
-	baseURL := m.GoStorageHome
-	if !strings.HasSuffix(baseURL, "/") {
-		baseURL += "/"
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
 	}
-	apiURL := fmt.Sprintf("%s?mode=json&include=all", baseURL)
+	return fmt.Sprintf("%s?mode=json&include=all", base)
+}
 
-	client := &http.Client{
-		Timeout: m.HTTPTimeout,
+func (m *Manager) setReleases(releases []GoRelease) {
+	m.releasesMu.Lock()
+	defer m.releasesMu.Unlock()
+	m.releases = releases
+	m.releasesLoaded = time.Now()
+}
+
+// readLocalReleases reads the release index of a local directory mirror.
+func readLocalReleases(dir string) ([]GoRelease, error) {
+	var releases []GoRelease
+	if err := readJSONFile(filepath.Join(dir, localIndexFile), &releases); err != nil {
+		return nil, fmt.Errorf("failed to read Go releases from %v: %w", dir, err)
+	}
+	return releases, nil
+}
+
+// downloadGoReleases fetches the release index from apiURL. If a cached copy
+// exists then the request is made conditional on it having changed.
+func (m *Manager) downloadGoReleases(ctx context.Context, apiURL string, cached []GoRelease, info *releaseIndexInfo) ([]GoRelease, error) {
+	var releases []GoRelease
+	err := m.Retry.Do(ctx, func() (retryable bool, err error) {
+		releases, retryable, err = m.requestGoReleases(ctx, apiURL, cached, info)
+		return retryable, err
+	})
+	return releases, err
+}
+
+// requestGoReleases makes a single request for the release index. retryable
+// reports whether a failed request should be retried.
+func (m *Manager) requestGoReleases(ctx context.Context, apiURL string, cached []GoRelease, info *releaseIndexInfo) (releases []GoRelease, retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if cached != nil {
+		if info.ETag != "" {
+			req.Header.Set("If-None-Match", info.ETag)
+		}
+		if info.LastModified != "" {
+			req.Header.Set("If-Modified-Since", info.LastModified)
+		}
 	}
 
-	resp, err := client.Get(apiURL)
+	resp, err := m.httpClient().Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Go releases: %w", err)
+		return nil, true, fmt.Errorf("failed to fetch Go releases: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request %s failed: %s", apiURL, resp.Status)
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		m.Logger.Debug("Release index not modified.")
+		info.Validated = time.Now()
+		if err := writeJSONFile(m.releaseIndexMetaFile(), info); err != nil {
+			m.Logger.WithError(err).Warn("Failed to update release index cache metadata.")
+		}
+		return cached, false, nil
+	}
+	if retryable, err := common.CheckResponse(resp); err != nil {
+		return nil, retryable, fmt.Errorf("API request %s failed: %w", apiURL, err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read API response: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, false, fmt.Errorf("failed to decode API response: %w", err)
+	}
+
+	m.writeCachedReleases(body, &releaseIndexInfo{
+		URL:          apiURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Validated:    time.Now(),
+	})
+	return releases, false, nil
+}
+
+// readCachedReleases returns the cached release index if it was fetched from
+// one of the GoStorageHome mirrors. It returns nil if there is no usable cache.
+func (m *Manager) readCachedReleases() ([]GoRelease, *releaseIndexInfo) {
+	info := &releaseIndexInfo{}
+	if err := readJSONFile(m.releaseIndexMetaFile(), info); err != nil {
+		return nil, info
+	}
+	known := false
+	for _, base := range m.storageHomes() {
+		if info.URL == releaseIndexURL(base) {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, &releaseIndexInfo{}
 	}
 
 	var releases []GoRelease
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, fmt.Errorf("failed to decode API response: %w", err)
+	if err := readJSONFile(m.releaseIndexFile(), &releases); err != nil {
+		m.Logger.WithError(err).Debug("Ignoring unreadable release index cache.")
+		return nil, info
 	}
+	return releases, info
+}
 
-	return releases, nil
+// writeCachedReleases stores the raw release index and its metadata in the
+// cache directory. Failures are logged because the cache is only an
+// optimization.
+func (m *Manager) writeCachedReleases(body []byte, info *releaseIndexInfo) {
+	if err := writeFileAtomic(m.releaseIndexFile(), body); err != nil {
+		m.Logger.WithError(err).Warn("Failed to cache release index.")
+		return
+	}
+	if err := writeJSONFile(m.releaseIndexMetaFile(), info); err != nil {
+		m.Logger.WithError(err).Warn("Failed to write release index cache metadata.")
+	}
 }
 
 // findArchiveFile finds the archive file for the given OS/arch combination
@@ -0,0 +1,183 @@
+package gvm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+const testReleaseIndex = `[{"version":"go1.22.5","stable":true,"files":[` +
+	`{"filename":"go1.22.5.linux-amd64.tar.gz","os":"linux","arch":"amd64","version":"go1.22.5",` +
+	`"sha256":"904b924d435eaea086515bc63235b192ea441bd8c9b198c507e85009e6e4c7f0","size":68958945,"kind":"archive"}]}]`
+
+func newTestManager(t *testing.T, storageHome string) *Manager {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	m := &Manager{
+		Home:          t.TempDir(),
+		GOOS:          "linux",
+		GOARCH:        "amd64",
+		GoStorageHome: storageHome,
+		Retry:         common.RetryPolicy{MaxAttempts: 3},
+		Logger:        logger,
+	}
+	require.NoError(t, m.Init())
+	return m
+}
+
+func TestFetchGoReleasesCache(t *testing.T) {
+	const etag = `"v1"`
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = io.WriteString(w, testReleaseIndex)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+
+	releases, err := m.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.EqualValues(t, 1, requests.Load())
+
+	// Repeated calls are served from memory.
+	_, err = m.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, requests.Load())
+
+	// A new Manager reuses the on-disk cache within the TTL.
+	m2 := &Manager{Home: m.Home, GoStorageHome: srv.URL, Logger: m.Logger}
+	require.NoError(t, m2.Init())
+	releases, err = m2.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.EqualValues(t, 1, requests.Load())
+
+	// After the TTL expires the cache is revalidated.
+	m3 := &Manager{Home: m.Home, GoStorageHome: srv.URL, Logger: m.Logger, ReleaseIndexTTL: time.Nanosecond}
+	require.NoError(t, m3.Init())
+	releases, err = m3.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.EqualValues(t, 2, requests.Load())
+	assert.EqualValues(t, 1, notModified.Load())
+
+	// When the server is unreachable the cached copy is used.
+	srv.Close()
+	m4 := &Manager{Home: m.Home, GoStorageHome: srv.URL, Logger: m.Logger, ReleaseIndexTTL: time.Nanosecond, Retry: m.Retry}
+	require.NoError(t, m4.Init())
+	releases, err = m4.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.Equal(t, "go1.22.5", releases[0].Version)
+}
+
+func TestFetchGoReleasesRetry(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = io.WriteString(w, testReleaseIndex)
+		}
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+	releases, err := m.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.EqualValues(t, 3, requests.Load())
+}
+
+func TestFetchGoReleasesNoRetry(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+	_, err := m.fetchGoReleases(context.Background())
+	var statusErr *common.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	assert.EqualValues(t, 1, requests.Load())
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestFetchGoReleasesHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, testReleaseIndex)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+	m.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer secret")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	releases, err := m.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+}
+
+func TestFetchGoReleasesOffline(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = io.WriteString(w, testReleaseIndex)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+	m.Offline = true
+	_, err := m.fetchGoReleases(context.Background())
+	require.ErrorIs(t, err, ErrOffline)
+
+	// Populate the cache.
+	m.Offline = false
+	_, err = m.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+
+	// An expired cache is used without revalidation.
+	m2 := &Manager{Home: m.Home, GoStorageHome: srv.URL, Logger: m.Logger, ReleaseIndexTTL: time.Nanosecond, Offline: true}
+	require.NoError(t, m2.Init())
+	releases, err := m2.fetchGoReleases(context.Background())
+	require.NoError(t, err)
+	require.Len(t, releases, 1)
+	assert.EqualValues(t, 1, requests.Load())
+}
@@ -0,0 +1,146 @@
+package gvm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// InstallArchive installs a Go binary distribution from a local .tar.gz or
+// .zip archive. The version is read from the go/VERSION file inside the
+// archive. If sha256 is not empty the archive must match the given hash. It
+// returns the installed version and its GOROOT.
+func (m *Manager) InstallArchive(path, sha256 string) (*GoVersion, string, error) {
+	return m.InstallArchiveContext(context.Background(), path, sha256)
+}
+
+// InstallArchiveContext is like InstallArchive but stops extracting the
+// archive when ctx is done. Partial installations are removed.
+func (m *Manager) InstallArchiveContext(ctx context.Context, path, sha256 string) (*GoVersion, string, error) {
+	if sha256 != "" {
+		if err := common.VerifyFile(path, 0, sha256); err != nil {
+			return nil, "", err
+		}
+	}
+
+	version, err := archiveVersion(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := m.checkArchivePlatform(path); err != nil {
+		return nil, "", err
+	}
+
+	var dir string
+	err = m.withLock(ctx, m.versionLock(version), func() error {
+		has, err := m.HasVersionContext(ctx, version)
+		if err != nil {
+			return err
+		}
+		if has {
+			return fmt.Errorf("version %v is already installed", version)
+		}
+		dir, err = m.extractTo(ctx, m.VersionGoROOT(version), path)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return version, dir, nil
+}
+
+// InstallURL downloads a Go binary distribution archive from the given URL
+// and installs it with InstallArchive. file:// URLs are supported.
+func (m *Manager) InstallURL(url, sha256 string) (*GoVersion, string, error) {
+	return m.InstallURLContext(context.Background(), url, sha256)
+}
+
+// InstallURLContext is like InstallURL but stops when ctx is done.
+func (m *Manager) InstallURLContext(ctx context.Context, url, sha256 string) (*GoVersion, string, error) {
+	if _, local := common.FileURLPath(url); m.Offline && !local {
+		return nil, "", fmt.Errorf("cannot download %v: %w", url, ErrOffline)
+	}
+
+	tmp, err := os.MkdirTemp("", "gvm-archive")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	path, err := m.downloadFile(ctx, url, tmp, 0)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed downloading from %v: %w", url, err)
+	}
+
+	return m.InstallArchiveContext(ctx, path, sha256)
+}
+
+// archiveVersion returns the Go version declared by the go/VERSION file of a
+// binary distribution archive.
+func archiveVersion(path string) (*GoVersion, error) {
+	data, err := common.ReadArchiveFile(path, "go/VERSION")
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect Go version of %v: %w", path, err)
+	}
+
+	// The first line holds the version (e.g. go1.22.5). Newer releases add
+	// more lines with build metadata.
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	name := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(name, "go") {
+		return nil, fmt.Errorf("unsupported Go version %q in %v", name, path)
+	}
+
+	version, err := ParseVersion(strings.TrimPrefix(name, "go"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Go version %q in %v: %w", name, path, err)
+	}
+	return version, nil
+}
+
+// archiveFileRegexp matches the GOOS and GOARCH in the file name of a binary
+// distribution archive, e.g. go1.22.5.linux-amd64.tar.gz.
+var archiveFileRegexp = regexp.MustCompile(`\.([a-z0-9]+)-([a-z0-9]+)\.(?:tar\.gz|tgz|zip)$`)
+
+// checkArchivePlatform returns an error if the binary distribution archive is
+// not for the Manager's GOOS and GOARCH. The platform is taken from the
+// go/pkg/tool/<goos>_<goarch> directory of the archive or, if the archive
+// has none, from its file name.
+func (m *Manager) checkArchivePlatform(path string) error {
+	goarch := m.GOARCH
+	if goarch == "armv6l" {
+		goarch = "arm"
+	}
+	want := m.GOOS + "_" + goarch
+
+	var platform string
+	err := common.WalkArchive(path, func(name string) bool {
+		dir, ok := strings.CutPrefix(name, "go/pkg/tool/")
+		if !ok {
+			return true
+		}
+		platform, _, _ = strings.Cut(dir, "/")
+		return platform == ""
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %w", path, err)
+	}
+	if platform == "" {
+		match := archiveFileRegexp.FindStringSubmatch(filepath.Base(path))
+		if match == nil {
+			return fmt.Errorf("failed to detect the platform of %v", path)
+		}
+		platform = match[1] + "_" + strings.Replace(match[2], "armv6l", "arm", 1)
+	}
+
+	if platform != want {
+		return fmt.Errorf("archive %v is for %v, not %v", path, strings.Replace(platform, "_", "/", 1), strings.Replace(want, "_", "/", 1))
+	}
+	return nil
+}
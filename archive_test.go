@@ -0,0 +1,120 @@
+package gvm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestArchive writes a minimal Go binary distribution archive for the
+// given version to path and returns its SHA-256 hash. extraFiles are added to
+// the archive as empty files.
+func writeTestArchive(t *testing.T, path, version string, extraFiles ...string) string {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	h := sha256.New()
+	zw := gzip.NewWriter(io.MultiWriter(f, h))
+	tw := tar.NewWriter(zw)
+	type testFile struct {
+		name, body string
+		mode       int64
+	}
+	files := []testFile{
+		{name: "go/VERSION", body: "go" + version + "\ntime 2024-06-27T20:11:12Z\n", mode: 0o644},
+		{name: "go/bin/go", body: "#!/bin/sh\necho go version go" + version + "\n", mode: 0o755},
+	}
+	for _, name := range extraFiles {
+		files = append(files, testFile{name: name, mode: 0o644})
+	}
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Mode:     file.mode,
+			Size:     int64(len(file.body)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(file.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestInstallArchive(t *testing.T) {
+	m := newTestManager(t, "https://go.dev/dl")
+
+	archive := filepath.Join(t.TempDir(), "go1.22.5.linux-amd64.tar.gz")
+	sum := writeTestArchive(t, archive, "1.22.5")
+
+	_, _, err := m.InstallArchive(archive, "00")
+	require.Error(t, err)
+
+	ver, dir, err := m.InstallArchive(archive, sum)
+	require.NoError(t, err)
+	assert.Equal(t, "1.22.5", ver.String())
+	assert.Equal(t, m.VersionGoROOT(ver), dir)
+	assert.FileExists(t, filepath.Join(dir, "bin", "go"))
+
+	// Installing the same version again fails.
+	_, _, err = m.InstallArchive(archive, "")
+	assert.Error(t, err)
+}
+
+func TestInstallArchiveTakesVersionLock(t *testing.T) {
+	m := newTestManager(t, "https://go.dev/dl")
+	m.LockTimeout = 100 * time.Millisecond
+
+	archive := filepath.Join(t.TempDir(), "go1.22.5.linux-amd64.tar.gz")
+	writeTestArchive(t, archive, "1.22.5")
+
+	l, err := m.lock(context.Background(), m.versionLock(MustParseVersion("1.22.5")))
+	require.NoError(t, err)
+	defer l.unlock()
+
+	_, _, err = m.InstallArchive(archive, "")
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.NoDirExists(t, m.VersionGoROOT(MustParseVersion("1.22.5")))
+}
+
+func TestInstallArchivePlatform(t *testing.T) {
+	m := newTestManager(t, "https://go.dev/dl")
+	dir := t.TempDir()
+
+	// The platform is read from go/pkg/tool.
+	archive := filepath.Join(dir, "go.tar.gz")
+	writeTestArchive(t, archive, "1.22.5", "go/pkg/tool/darwin_arm64/compile")
+	_, _, err := m.InstallArchive(archive, "")
+	assert.ErrorContains(t, err, "is for darwin/arm64, not linux/amd64")
+
+	archive = filepath.Join(dir, "go1.22.5.tar.gz")
+	writeTestArchive(t, archive, "1.22.5", "go/pkg/tool/linux_amd64/compile")
+	_, _, err = m.InstallArchive(archive, "")
+	assert.NoError(t, err)
+
+	// Archives without tools fall back to the file name.
+	archive = filepath.Join(dir, "go1.21.0.windows-amd64.tar.gz")
+	writeTestArchive(t, archive, "1.21.0")
+	_, _, err = m.InstallArchive(archive, "")
+	assert.ErrorContains(t, err, "is for windows/amd64, not linux/amd64")
+
+	archive = filepath.Join(dir, "toolchain.tar.gz")
+	writeTestArchive(t, archive, "1.21.0")
+	_, _, err = m.InstallArchive(archive, "")
+	assert.ErrorContains(t, err, "failed to detect the platform")
+}
@@ -0,0 +1,109 @@
+package gvm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PruneArchives removes the cached archives and toolchain module zips of
+// versions that are not installed, including partial downloads. It returns
+// the paths of the removed files.
+func (m *Manager) PruneArchives() ([]string, error) {
+	return m.PruneArchivesContext(context.Background())
+}
+
+// PruneArchivesContext is like PruneArchives but stops removing files when
+// ctx is done.
+func (m *Manager) PruneArchivesContext(ctx context.Context) ([]string, error) {
+	files, err := os.ReadDir(m.archivesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	byVersion := map[string][]string{}
+	for _, f := range files {
+		v, ok := m.cachedArchiveVersion(f.Name())
+		if !ok {
+			continue
+		}
+		if _, found := byVersion[v]; !found {
+			versions = append(versions, v)
+		}
+		byVersion[v] = append(byVersion[v], f.Name())
+	}
+
+	var removed []string
+	for _, v := range versions {
+		version, err := ParseVersion(v)
+		if err != nil {
+			continue
+		}
+
+		// Archives are fetched while holding the version lock, so holding it
+		// keeps an install from using an archive while it is removed.
+		err = m.withLock(ctx, m.versionLock(version), func() error {
+			has, err := m.HasVersionContext(ctx, version)
+			if err != nil || has {
+				return err
+			}
+			for _, name := range byVersion[v] {
+				path := filepath.Join(m.archivesDir, name)
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				removed = append(removed, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// removeCachedArchives removes the cached archives of the version. The caller
+// must hold the version lock.
+func (m *Manager) removeCachedArchives(version *GoVersion) error {
+	files, err := os.ReadDir(m.archivesDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if v, ok := m.cachedArchiveVersion(f.Name()); ok && v == version.String() {
+			if err := os.Remove(filepath.Join(m.archivesDir, f.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cachedArchiveVersion returns the Go version of a file in the archives cache
+// if it is an archive or toolchain module zip for the Manager's GOOS and
+// GOARCH, or a partial download or .ziphash file of one.
+func (m *Manager) cachedArchiveVersion(name string) (string, bool) {
+	for _, suffix := range []string{".part", ".chunks", ".state", ".ziphash"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+
+	rest, ok := strings.CutPrefix(name, "toolchain@v0.0.1-go")
+	if !ok {
+		if rest, ok = strings.CutPrefix(name, "go"); !ok {
+			return "", false
+		}
+	}
+
+	loc := archiveFileRegexp.FindStringSubmatchIndex(rest)
+	if loc == nil {
+		return "", false
+	}
+	goos, goarch := rest[loc[2]:loc[3]], rest[loc[4]:loc[5]]
+	if goos != m.GOOS || strings.Replace(goarch, "armv6l", "arm", 1) != strings.Replace(m.GOARCH, "armv6l", "arm", 1) {
+		return "", false
+	}
+	return rest[:loc[0]], true
+}
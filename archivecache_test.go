@@ -0,0 +1,60 @@
+package gvm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedArchiveVersion(t *testing.T) {
+	m := &Manager{GOOS: "linux", GOARCH: "arm"}
+	tests := map[string]string{
+		"go1.22.5.linux-armv6l.tar.gz":              "1.22.5",
+		"go1.23rc1.linux-armv6l.tar.gz.part":        "1.23rc1",
+		"go1.22.5.linux-armv6l.tar.gz.chunks":       "1.22.5",
+		"toolchain@v0.0.1-go1.21.0.linux-arm.zip":   "1.21.0",
+		"go1.22.5.linux-amd64.tar.gz":               "",
+		"go1.22.5.darwin-arm64.tar.gz":              "",
+		"toolchain@v0.0.1-go1.21.0.linux-amd64.zip": "",
+		"index.json": "",
+	}
+	for name, want := range tests {
+		got, ok := m.cachedArchiveVersion(name)
+		assert.Equal(t, want != "", ok, name)
+		assert.Equal(t, want, got, name)
+	}
+}
+
+func TestCachedArchivesRemoved(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.21.0", "1.22.5")
+	m := newTestManager(t, mirror)
+
+	for _, v := range []string{"1.21.0", "1.22.5"} {
+		_, err := m.Install(MustParseVersion(v))
+		require.NoError(t, err)
+	}
+	archive := func(v string) string {
+		return filepath.Join(m.archivesDir, "go"+v+".linux-amd64.tar.gz")
+	}
+	require.FileExists(t, archive("1.21.0"))
+	require.FileExists(t, archive("1.22.5"))
+
+	// Removing a version removes its cached archive.
+	require.NoError(t, m.Remove(MustParseVersion("1.21.0")))
+	assert.NoFileExists(t, archive("1.21.0"))
+	assert.FileExists(t, archive("1.22.5"))
+
+	// Pruning keeps the archives of installed versions.
+	other := filepath.Join(m.archivesDir, "go1.20.0.darwin-arm64.tar.gz")
+	require.NoError(t, os.WriteFile(archive("1.19.0")+".part", nil, 0o644))
+	require.NoError(t, os.WriteFile(other, nil, 0o644))
+	removed, err := m.PruneArchives()
+	require.NoError(t, err)
+	assert.Equal(t, []string{archive("1.19.0") + ".part"}, removed)
+	assert.FileExists(t, archive("1.22.5"))
+	assert.FileExists(t, other)
+}
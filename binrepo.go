@@ -1,16 +1,20 @@
 package gvm
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/andrewkroh/gvm/common"
 )
 
-func (m *Manager) installBinary(version *GoVersion) (string, error) {
+// fetchBinary returns the path to the verified binary distribution archive of
+// the version from GoStorageHome.
+func (m *Manager) fetchBinary(ctx context.Context, version *GoVersion) (string, error) {
 	// Fetch releases to find the correct file
-	releases, err := m.fetchGoReleases()
+	releases, err := m.fetchGoReleases(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch releases: %w", err)
 	}
@@ -35,26 +39,83 @@ func (m *Manager) installBinary(version *GoVersion) (string, error) {
 		return "", common.ErrNotFound
 	}
 
-	godir := m.versionDir(version)
+	return m.fetchArchive(ctx, file)
+}
+
+// fetchArchive returns the path to a verified copy of the archive in the
+// archives cache, downloading it if it is not already cached.
+func (m *Manager) fetchArchive(ctx context.Context, file *GoFile) (string, error) {
+	path := filepath.Join(m.archivesDir, filepath.Base(file.Filename))
 
-	tmp, err := os.MkdirTemp("", godir)
+	l, err := m.lock(ctx, "archive-"+filepath.Base(file.Filename))
 	if err != nil {
 		return "", err
 	}
-	defer os.RemoveAll(tmp)
+	defer l.unlock()
 
-	// Construct download URL using the filename from the API
-	goURL := constructDownloadURL(m.GoStorageHome, file.Filename)
-	path, err := common.DownloadFile(goURL, tmp, m.HTTPTimeout, common.DefaultRetryParams)
-	if err != nil {
-		return "", fmt.Errorf("failed downloading from %v: %w", goURL, err)
+	if _, err := os.Stat(path); err == nil {
+		err = common.VerifyFile(path, file.Size, file.SHA256)
+		if err == nil {
+			m.Logger.WithField("file", path).Debug("Using cached archive.")
+			return path, nil
+		}
+		m.Logger.WithError(err).Info("Discarding cached archive that failed verification.")
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
 	}
 
-	return extractTo(m.VersionGoROOT(version), path)
+	return m.downloadArchive(ctx, file, m.archivesDir)
 }
 
+// AvailableBinaries returns the versions available from the binary (non-source)
+// providers.
 func (m *Manager) AvailableBinaries() ([]*GoVersion, error) {
-	releases, err := m.fetchGoReleases()
+	return m.AvailableBinariesContext(context.Background())
+}
+
+// AvailableBinariesContext is like AvailableBinaries but stops when ctx is
+// done.
+func (m *Manager) AvailableBinariesContext(ctx context.Context) ([]*GoVersion, error) {
+	versionSet := make(map[string]*GoVersion)
+	var firstErr error
+	listed := false
+	for _, p := range m.providers() {
+		if p.Source() {
+			continue
+		}
+		versions, err := p.List(ctx, m)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			m.Logger.WithError(err).WithField("provider", p.Name()).Debug("Failed to list versions.")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		listed = true
+		for _, ver := range versions {
+			versionSet[ver.String()] = ver
+		}
+	}
+	if !listed && firstErr != nil {
+		return nil, firstErr
+	}
+
+	list := make([]*GoVersion, 0, len(versionSet))
+	for _, ver := range versionSet {
+		list = append(list, ver)
+	}
+	sortVersions(list)
+	return list, nil
+}
+
+// availableReleaseBinaries returns the versions in the release index that have
+// an archive for the Manager's GOOS and GOARCH.
+func (m *Manager) availableReleaseBinaries(ctx context.Context) ([]*GoVersion, error) {
+	releases, err := m.fetchGoReleases(ctx)
 	if err != nil {
 		return nil, err
 	}
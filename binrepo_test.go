@@ -0,0 +1,151 @@
+package gvm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// writeTestMirror writes a local directory mirror containing a binary
+// distribution of each version for linux/amd64.
+func writeTestMirror(t *testing.T, dir string, versions ...string) {
+	t.Helper()
+
+	releases := make([]GoRelease, 0, len(versions))
+	for _, v := range versions {
+		name := "go" + v + ".linux-amd64.tar.gz"
+		path := filepath.Join(dir, name)
+		sum := writeTestArchive(t, path, v)
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+
+		releases = append(releases, GoRelease{
+			Version: "go" + v,
+			Stable:  true,
+			Files: []GoFile{{
+				Filename: name,
+				OS:       "linux",
+				Arch:     "amd64",
+				Version:  "go" + v,
+				SHA256:   sum,
+				Size:     fi.Size(),
+				Kind:     "archive",
+			}},
+		})
+	}
+	require.NoError(t, writeJSONFile(filepath.Join(dir, localIndexFile), releases))
+}
+
+func TestInstallBinaryLocalMirror(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.22.5")
+
+	for _, home := range []string{mirror, mustFileURL(t, mirror)} {
+		t.Run(home, func(t *testing.T) {
+			m := newTestManager(t, home)
+			m.Offline = true
+
+			versions, err := m.AvailableBinaries()
+			require.NoError(t, err)
+			require.Len(t, versions, 1)
+
+			dir, err := m.Install(MustParseVersion("1.22.5"))
+			require.NoError(t, err)
+			assert.FileExists(t, filepath.Join(dir, "bin", "go"))
+		})
+	}
+}
+
+func mustFileURL(t *testing.T, path string) string {
+	t.Helper()
+	u, err := common.FileURL(path)
+	require.NoError(t, err)
+	return u
+}
+
+func TestInstallBinaryChecksumMismatch(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.22.5")
+
+	// Replace the archive after the index was written.
+	writeTestArchive(t, filepath.Join(mirror, "go1.22.5.linux-amd64.tar.gz"), "1.22.6")
+
+	m := newTestManager(t, mirror)
+	_, err := m.Install(MustParseVersion("1.22.5"))
+	var checksumErr *common.ChecksumError
+	require.ErrorAs(t, err, &checksumErr)
+
+	assert.NoFileExists(t, filepath.Join(m.archivesDir, "go1.22.5.linux-amd64.tar.gz"))
+	has, err := m.HasVersion(MustParseVersion("1.22.5"))
+	require.NoError(t, err)
+	assert.False(t, has)
+}
+
+func TestInstallBinaryProgress(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.22.5")
+	files := http.FileServer(http.Dir(mirror))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.ServeFile(w, r, filepath.Join(mirror, localIndexFile))
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	var events []ProgressEvent
+	m := newTestManager(t, srv.URL)
+	m.Progress = func(ev ProgressEvent) { events = append(events, ev) }
+
+	_, err := m.Install(MustParseVersion("1.22.5"))
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+
+	first, last := events[0], events[len(events)-1]
+	assert.Equal(t, PhaseDownload, first.Phase)
+	assert.Equal(t, "go1.22.5.linux-amd64.tar.gz", first.File)
+	assert.Greater(t, first.Total, int64(0))
+	assert.Equal(t, PhaseExtract, last.Phase)
+	assert.EqualValues(t, 2, last.Current)
+
+	var downloaded int64
+	for _, ev := range events {
+		if ev.Phase == PhaseDownload {
+			downloaded = ev.Current
+		}
+	}
+	assert.Equal(t, first.Total, downloaded)
+}
+
+func TestInstallBinaryCanceled(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.22.5")
+	index, err := os.ReadFile(filepath.Join(mirror, localIndexFile))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			_, _ = w.Write(index)
+			return
+		}
+		// Cancel while the archive is being downloaded.
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+	_, err = m.InstallContext(ctx, MustParseVersion("1.22.5"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoDirExists(t, m.VersionGoROOT(MustParseVersion("1.22.5")))
+}
@@ -0,0 +1,170 @@
+package gvm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// maxBootstrapDepth limits how many toolchains are built from source to
+// bootstrap a build. Building Go 1.26 from Go 1.4 takes five steps.
+const maxBootstrapDepth = 8
+
+// bootstrapRequirements lists the minimum bootstrap toolchain of the Go
+// releases up to 1.21. Starting with Go 1.22 a release needs the latest patch
+// release of the Go version two minor versions back, rounded down to an even
+// minor version (see requiredBootstrap).
+var bootstrapRequirements = []struct {
+	minMinor  int    // First minor release of Go 1 with the requirement.
+	bootstrap string // Minimum bootstrap version.
+
+	// fallbackBelow, if set, allows any release older than it to bootstrap
+	// when the bootstrap version has no binary release for the platform.
+	fallbackBelow string
+}{
+	{minMinor: 20, bootstrap: "1.17.13"},
+	// Go 1.4 has no binary releases for newer platforms like darwin/arm64.
+	{minMinor: 5, bootstrap: "1.4", fallbackBelow: "1.20"},
+}
+
+// requiredBootstrap returns the minimum Go version needed to build the version
+// from source. It returns nil for Go 1.4 and older, which are written in C.
+func requiredBootstrap(version *GoVersion) *GoVersion {
+	major, minor := version.segments()
+	if major != 1 {
+		return nil
+	}
+	if minor >= 22 {
+		// Go 1.N needs Go 1.M.6 where M is N-2 rounded down to an even number.
+		return MustParseVersion(fmt.Sprintf("1.%d.6", (minor-2)&^1))
+	}
+	for _, r := range bootstrapRequirements {
+		if minor >= r.minMinor {
+			return MustParseVersion(r.bootstrap)
+		}
+	}
+	return nil
+}
+
+// goversionRegexp matches the minor version in src/internal/goversion.
+var goversionRegexp = regexp.MustCompile(`(?m)^const Version = (\d+)`)
+
+// sourceTreeVersion returns the Go release that the source tree in repo will
+// become as recorded by src/internal/goversion/goversion.go. On master this
+// is the next release, on release branches the release of the branch.
+func sourceTreeVersion(repo string) (*GoVersion, error) {
+	data, err := os.ReadFile(filepath.Join(repo, "src", "internal", "goversion", "goversion.go"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine the Go version of the source tree: %w", err)
+	}
+	match := goversionRegexp.FindSubmatch(data)
+	if match == nil {
+		return nil, fmt.Errorf("failed to determine the Go version of the source tree: no version in goversion.go")
+	}
+	minor, _ := strconv.Atoi(string(match[1]))
+	return MustParseVersion(fmt.Sprintf("1.%d.0", minor)), nil
+}
+
+type bootstrapDepthKey struct{}
+
+// bootstrapGOROOT returns the GOROOT of a toolchain that can build the
+// version from source. BootstrapGOROOT or $GOROOT_BOOTSTRAP is used if set.
+// Otherwise the oldest installed release that meets the version's requirement
+// is used, or the required release is installed. If the required release has
+// no binary distribution it is built from source, bootstrapped the same way.
+// It returns an empty string if the version does not need a bootstrap
+// toolchain. The Go version of tip and named versions is read from the source
+// tree at srcDir.
+func (m *Manager) bootstrapGOROOT(ctx context.Context, version *GoVersion, srcDir string) (string, error) {
+	if goroot := m.BootstrapGOROOT; goroot != "" {
+		return goroot, nil
+	}
+	if goroot := os.Getenv("GOROOT_BOOTSTRAP"); goroot != "" {
+		return goroot, nil
+	}
+
+	if !version.isRelease() {
+		var err error
+		if version, err = sourceTreeVersion(srcDir); err != nil {
+			return "", err
+		}
+	}
+	required := requiredBootstrap(version)
+	if required == nil {
+		return "", nil
+	}
+
+	installed, err := m.InstalledContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, ver := range installed {
+		// Installed is sorted so the first match is the oldest.
+		if ver.Stable() && !ver.LessThan(required) {
+			m.logger(ctx).Debugf("Using Go %v to bootstrap Go %v.", ver, version)
+			return m.VersionGoROOT(ver), nil
+		}
+	}
+
+	depth, _ := ctx.Value(bootstrapDepthKey{}).(int)
+	if depth >= maxBootstrapDepth {
+		return "", fmt.Errorf("cannot bootstrap Go %v: more than %d toolchains would need to be built", version, maxBootstrapDepth)
+	}
+	ctx = context.WithValue(ctx, bootstrapDepthKey{}, depth+1)
+
+	fallback, err := m.bootstrapFallback(ctx, required)
+	if err != nil {
+		return "", err
+	}
+	if fallback != nil {
+		m.logger(ctx).Infof("Go %v has no binary release for %v/%v, using Go %v instead.", required, m.GOOS, m.GOARCH, fallback)
+		required = fallback
+	}
+
+	m.logger(ctx).Infof("Installing Go %v to bootstrap Go %v.", required, version)
+	m.buildProgress(fmt.Sprintf("Installing bootstrap Go %v", required))
+	goroot, err := m.InstallContext(ctx, required)
+	if err != nil {
+		return "", fmt.Errorf("failed to install bootstrap Go %v: %w", required, err)
+	}
+	return goroot, nil
+}
+
+// bootstrapFallback returns the newest release with a binary distribution for
+// the platform that may replace the required bootstrap version when it has
+// none. It returns nil if the required version has a binary distribution, no
+// fallback is allowed, or the available binaries cannot be listed.
+func (m *Manager) bootstrapFallback(ctx context.Context, required *GoVersion) (*GoVersion, error) {
+	var below *GoVersion
+	for _, r := range bootstrapRequirements {
+		if r.fallbackBelow != "" && r.bootstrap == required.String() {
+			below = MustParseVersion(r.fallbackBelow)
+		}
+	}
+	if below == nil {
+		return nil, nil
+	}
+
+	binaries, err := m.AvailableBinariesContext(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		m.logger(ctx).WithError(err).Debug("Failed to list binary releases for a bootstrap fallback.")
+		return nil, nil
+	}
+
+	var fallback *GoVersion
+	for _, ver := range binaries {
+		if ver.String() == required.String() {
+			return nil, nil
+		}
+		if ver.Stable() && !ver.LessThan(required) && ver.LessThan(below) && (fallback == nil || fallback.LessThan(ver)) {
+			fallback = ver
+		}
+	}
+	return fallback, nil
+}
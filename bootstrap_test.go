@@ -0,0 +1,130 @@
+package gvm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredBootstrap(t *testing.T) {
+	tests := map[string]string{
+		"1.4.3":   "",
+		"1.5":     "1.4",
+		"1.19.13": "1.4",
+		"1.20":    "1.17.13",
+		"1.21.5":  "1.17.13",
+		"1.22.0":  "1.20.6",
+		"1.23rc1": "1.20.6",
+		"1.24.2":  "1.22.6",
+		"1.25.0":  "1.22.6",
+		"1.26.0":  "1.24.6",
+		"1.27.1":  "1.24.6",
+		"1.28.0":  "1.26.6",
+	}
+	for version, want := range tests {
+		got := requiredBootstrap(MustParseVersion(version))
+		if want == "" {
+			assert.Nil(t, got, version)
+			continue
+		}
+		if assert.NotNil(t, got, version) {
+			assert.Equal(t, want, got.String(), version)
+		}
+	}
+}
+
+func TestSourceTreeVersion(t *testing.T) {
+	repo := t.TempDir()
+	dir := filepath.Join(repo, "src", "internal", "goversion")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "goversion.go"),
+		[]byte("package goversion\n\n// Version is the minor version.\nconst Version = 27\n"), 0o644))
+
+	ver, err := sourceTreeVersion(repo)
+	require.NoError(t, err)
+	assert.Equal(t, "1.27.0", ver.String())
+}
+
+func TestBootstrapGOROOT(t *testing.T) {
+	t.Setenv("GOROOT_BOOTSTRAP", "")
+	dir := t.TempDir()
+	writeTestArchive(t, filepath.Join(dir, "go1.22.6.linux-amd64.tar.gz"), "1.22.6")
+
+	m := newTestManager(t, "")
+	m.Providers = []Provider{DirProvider{Dir: dir}}
+	target := MustParseVersion("1.24.0")
+
+	// The required version is installed when no installed version is new
+	// enough.
+	installVersionDir(t, m, "1.20.6")
+	goroot, err := m.bootstrapGOROOT(context.Background(), target, "")
+	require.NoError(t, err)
+	assert.Equal(t, m.VersionGoROOT(MustParseVersion("1.22.6")), goroot)
+	assert.FileExists(t, filepath.Join(goroot, "bin", "go"))
+
+	// The oldest installed version that meets the requirement is used.
+	installVersionDir(t, m, "1.23.1")
+	installVersionDir(t, m, "1.25rc1")
+	require.NoError(t, m.Remove(MustParseVersion("1.22.6")))
+	goroot, err = m.bootstrapGOROOT(context.Background(), target, "")
+	require.NoError(t, err)
+	assert.Equal(t, m.VersionGoROOT(MustParseVersion("1.23.1")), goroot)
+
+	// Versions written in C need no bootstrap.
+	goroot, err = m.bootstrapGOROOT(context.Background(), MustParseVersion("1.4.3"), "")
+	require.NoError(t, err)
+	assert.Empty(t, goroot)
+
+	// An explicit bootstrap toolchain takes precedence.
+	t.Setenv("GOROOT_BOOTSTRAP", "/opt/go")
+	goroot, err = m.bootstrapGOROOT(context.Background(), target, "")
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/go", goroot)
+	m.BootstrapGOROOT = "/usr/local/go"
+	goroot, err = m.bootstrapGOROOT(context.Background(), target, "")
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/local/go", goroot)
+}
+
+func TestBootstrapGOROOTFallback(t *testing.T) {
+	t.Setenv("GOROOT_BOOTSTRAP", "")
+	dir := t.TempDir()
+	writeTestArchive(t, filepath.Join(dir, "go1.16.15.linux-amd64.tar.gz"), "1.16.15")
+	writeTestArchive(t, filepath.Join(dir, "go1.17.13.linux-amd64.tar.gz"), "1.17.13")
+	writeTestArchive(t, filepath.Join(dir, "go1.20.6.linux-amd64.tar.gz"), "1.20.6")
+
+	m := newTestManager(t, "")
+	m.Providers = []Provider{DirProvider{Dir: dir}}
+
+	// Without a Go 1.4 binary the newest release below Go 1.20 is used.
+	goroot, err := m.bootstrapGOROOT(context.Background(), MustParseVersion("1.19.13"), "")
+	require.NoError(t, err)
+	assert.Equal(t, m.VersionGoROOT(MustParseVersion("1.17.13")), goroot)
+
+	// Go 1.4 is used when it has a binary release.
+	require.NoError(t, m.Remove(MustParseVersion("1.17.13")))
+	writeTestArchive(t, filepath.Join(dir, "go1.4.linux-amd64.tar.gz"), "1.4")
+	goroot, err = m.bootstrapGOROOT(context.Background(), MustParseVersion("1.19.13"), "")
+	require.NoError(t, err)
+	assert.Equal(t, m.VersionGoROOT(MustParseVersion("1.4")), goroot)
+}
+
+func TestBootstrapGOROOTDepth(t *testing.T) {
+	t.Setenv("GOROOT_BOOTSTRAP", "")
+	m := newTestManager(t, "")
+	m.Providers = []Provider{&emptyProvider{}}
+
+	ctx := context.WithValue(context.Background(), bootstrapDepthKey{}, maxBootstrapDepth)
+	_, err := m.bootstrapGOROOT(ctx, MustParseVersion("1.22.0"), "")
+	assert.ErrorContains(t, err, "cannot bootstrap")
+}
+
+// installVersionDir creates an empty installation of the version.
+func installVersionDir(t *testing.T, m *Manager, version string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(m.VersionGoROOT(MustParseVersion(version)), "bin"), 0o755))
+}
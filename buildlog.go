@@ -0,0 +1,254 @@
+package gvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// buildLogTimeFormat is the format of the timestamp in build log file names.
+// It sorts lexically in time order.
+const buildLogTimeFormat = "20060102T150405.000Z"
+
+// BuildError is returned when building a version from source fails. Log is
+// the path of the build log with the output of the git and build commands.
+type BuildError struct {
+	Err error
+	Log string
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("%v (build log: %v)", e.Err, e.Log)
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+type buildLogKey struct{}
+
+// buildLog is the build log that a context belongs to.
+type buildLog struct {
+	*logrus.Logger
+	f       *os.File
+	path    string
+	stamp   string // Time the log was created, in buildLogTimeFormat.
+	discard bool   // Remove the log if fn succeeds because nothing was built.
+}
+
+// logger returns the logger of the build log that ctx belongs to, or the
+// Manager's Logger if ctx is not part of a build.
+func (m *Manager) logger(ctx context.Context) logrus.FieldLogger {
+	if b, ok := ctx.Value(buildLogKey{}).(*buildLog); ok {
+		return b.Logger
+	}
+	return m.Logger
+}
+
+// withBuildLog runs fn with a context whose logger writes to a new build log
+// for the version in the logs directory, as well as to the logger of ctx. If
+// fn fails the error is returned as a *BuildError.
+func (m *Manager) withBuildLog(ctx context.Context, version *GoVersion, fn func(ctx context.Context) error) error {
+	stamp := time.Now().UTC().Format(buildLogTimeFormat)
+	path := filepath.Join(m.logsDir, m.versionDir(version)+"-"+stamp+".log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	b := &buildLog{Logger: logrus.New(), f: f, path: path, stamp: stamp}
+	defer func() { b.f.Close() }()
+	b.SetOutput(f)
+	b.SetLevel(logrus.DebugLevel)
+	b.SetFormatter(&logrus.TextFormatter{DisableColors: true, FullTimestamp: true})
+	b.AddHook(&forwardHook{to: m.logger(ctx)})
+
+	b.Infof("Building Go %v for %v/%v.", version, m.GOOS, m.GOARCH)
+	if err := fn(context.WithValue(ctx, buildLogKey{}, b)); err != nil {
+		b.WithError(err).Error("Build failed.")
+		// Keep the log of a nested bootstrap build that failed.
+		var buildErr *BuildError
+		if errors.As(err, &buildErr) {
+			return err
+		}
+		return &BuildError{Err: err, Log: b.path}
+	}
+	if b.discard {
+		b.f.Close()
+		os.Remove(b.path)
+		return nil
+	}
+	b.Info("Build succeeded.")
+	return nil
+}
+
+// renameBuildLog moves the build log of ctx to a log of the version, for
+// builds whose version is only known after running the git commands that are
+// logged. A failed rename is logged and the old log is kept.
+func (m *Manager) renameBuildLog(ctx context.Context, version *GoVersion) {
+	b, ok := ctx.Value(buildLogKey{}).(*buildLog)
+	if !ok {
+		return
+	}
+	path := filepath.Join(m.logsDir, m.versionDir(version)+"-"+b.stamp+".log")
+	if path == b.path {
+		return
+	}
+
+	// Windows cannot rename open files.
+	b.f.Close()
+	if err := os.Rename(b.path, path); err != nil {
+		m.Logger.WithError(err).Warnf("Failed to rename build log %v.", b.path)
+	} else {
+		b.path = path
+	}
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		m.Logger.WithError(err).Warnf("Failed to reopen build log %v.", b.path)
+		b.SetOutput(io.Discard)
+		return
+	}
+	b.f = f
+	b.SetOutput(f)
+}
+
+// discardBuildLog removes the build log of ctx if the build succeeds, for
+// builds that turned out to be up to date.
+func discardBuildLog(ctx context.Context) {
+	if b, ok := ctx.Value(buildLogKey{}).(*buildLog); ok {
+		b.discard = true
+	}
+}
+
+// forwardHook forwards the entries of a build log to another logger.
+type forwardHook struct {
+	to logrus.FieldLogger
+}
+
+func (h *forwardHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *forwardHook) Fire(e *logrus.Entry) error {
+	h.to.WithFields(e.Data).Log(e.Level, e.Message)
+	return nil
+}
+
+// BuildLogs returns the paths of the build logs of the version, oldest first.
+func (m *Manager) BuildLogs(version *GoVersion) ([]string, error) {
+	return m.BuildLogsContext(context.Background(), version)
+}
+
+// BuildLogsContext is like BuildLogs but returns ctx.Err() if ctx is done.
+func (m *Manager) BuildLogsContext(ctx context.Context, version *GoVersion) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(m.logsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := m.versionDir(version) + "-"
+	var logs []string
+	for _, f := range files {
+		if key, ok := buildLogKeyOf(f.Name()); ok && key+"-" == prefix {
+			logs = append(logs, filepath.Join(m.logsDir, f.Name()))
+		}
+	}
+	return logs, nil
+}
+
+// LatestBuildLog returns the path of the newest build log of the version. It
+// returns an error wrapping common.ErrNotFound if the version has no log.
+func (m *Manager) LatestBuildLog(version *GoVersion) (string, error) {
+	return m.LatestBuildLogContext(context.Background(), version)
+}
+
+// LatestBuildLogContext is like LatestBuildLog but returns ctx.Err() if ctx
+// is done.
+func (m *Manager) LatestBuildLogContext(ctx context.Context, version *GoVersion) (string, error) {
+	logs, err := m.BuildLogsContext(ctx, version)
+	if err != nil {
+		return "", err
+	}
+	if len(logs) == 0 {
+		return "", fmt.Errorf("no build log for version %v: %w", version, common.ErrNotFound)
+	}
+	return logs[len(logs)-1], nil
+}
+
+// PruneBuildLogs removes the build logs that are older than maxAge, except
+// the newest log of each version. It returns the paths of the removed logs.
+func (m *Manager) PruneBuildLogs(maxAge time.Duration) ([]string, error) {
+	return m.PruneBuildLogsContext(context.Background(), maxAge)
+}
+
+// PruneBuildLogsContext is like PruneBuildLogs but stops removing logs when
+// ctx is done.
+func (m *Manager) PruneBuildLogsContext(ctx context.Context, maxAge time.Duration) ([]string, error) {
+	files, err := os.ReadDir(m.logsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// ReadDir sorts by name, so the logs of a version are in time order and
+	// the newest is the last.
+	newest := map[string]string{}
+	for _, f := range files {
+		if key, ok := buildLogKeyOf(f.Name()); ok {
+			newest[key] = f.Name()
+		}
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var removed []string
+	for _, f := range files {
+		key, ok := buildLogKeyOf(f.Name())
+		if !ok || newest[key] == f.Name() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		if info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		path := filepath.Join(m.logsDir, f.Name())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// buildLogKeyOf returns the version directory name of a build log file name.
+func buildLogKeyOf(name string) (string, bool) {
+	base, ok := strings.CutSuffix(name, ".log")
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndexByte(base, '-')
+	if i < 0 {
+		return "", false
+	}
+	if _, err := time.Parse(buildLogTimeFormat, base[i+1:]); err != nil {
+		return "", false
+	}
+	return base[:i], true
+}
@@ -0,0 +1,112 @@
+package gvm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+func TestBuildLog(t *testing.T) {
+	m := newTestManager(t, "")
+	version := MustParseVersion("1.22.5")
+	ctx := context.Background()
+
+	_, err := m.LatestBuildLog(version)
+	assert.ErrorIs(t, err, common.ErrNotFound)
+
+	err = m.withBuildLog(ctx, version, func(ctx context.Context) error {
+		m.logger(ctx).Info("make.bash output")
+		return nil
+	})
+	require.NoError(t, err)
+	first, err := m.LatestBuildLog(version)
+	require.NoError(t, err)
+	assert.Contains(t, readFile(t, first), "make.bash output")
+
+	// A failed build returns the path of its log.
+	time.Sleep(5 * time.Millisecond)
+	boom := errors.New("boom")
+	err = m.withBuildLog(ctx, version, func(ctx context.Context) error {
+		return boom
+	})
+	var buildErr *BuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.ErrorIs(t, err, boom)
+	latest, err := m.LatestBuildLog(version)
+	require.NoError(t, err)
+	assert.Equal(t, latest, buildErr.Log)
+	assert.Contains(t, buildErr.Error(), latest)
+	assert.Contains(t, readFile(t, latest), "boom")
+
+	logs, err := m.BuildLogs(version)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first, latest}, logs)
+
+	// A failed bootstrap build reports its own log, and its output is also
+	// written to the log of the outer build.
+	time.Sleep(5 * time.Millisecond)
+	bootstrap := MustParseVersion("1.20.14")
+	err = m.withBuildLog(ctx, version, func(ctx context.Context) error {
+		return m.withBuildLog(ctx, bootstrap, func(ctx context.Context) error {
+			m.logger(ctx).Error("bootstrap failed")
+			return boom
+		})
+	})
+	require.ErrorAs(t, err, &buildErr)
+	bootstrapLog, err := m.LatestBuildLog(bootstrap)
+	require.NoError(t, err)
+	assert.Equal(t, bootstrapLog, buildErr.Log)
+	latest, err = m.LatestBuildLog(version)
+	require.NoError(t, err)
+	assert.Contains(t, readFile(t, latest), "bootstrap failed")
+}
+
+func TestPruneBuildLogs(t *testing.T) {
+	m := newTestManager(t, "")
+	old := time.Now().Add(-48 * time.Hour)
+	write := func(name string, mtime time.Time) string {
+		path := filepath.Join(m.logsDir, name)
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+		return path
+	}
+
+	v1 := m.versionDir(MustParseVersion("1.22.5"))
+	v2 := m.versionDir(MustParseVersion("tip"))
+	oldest := write(v1+"-20260101T000000.000Z.log", old)
+	older := write(v1+"-20260102T000000.000Z.log", old)
+	write(v1+"-20260103T000000.000Z.log", old)
+	write(v2+"-20260101T000000.000Z.log", old)
+	write(v2+"-20260102T000000.000Z.log", time.Now())
+	write(v2+"-20260103T000000.000Z.log", time.Now())
+	write("notes.txt", old)
+
+	removed, err := m.PruneBuildLogs(24 * time.Hour)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{oldest, older, filepath.Join(m.logsDir, v2+"-20260101T000000.000Z.log")}, removed)
+
+	files, err := os.ReadDir(m.logsDir)
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -1,11 +1,12 @@
 package gvm
 
 import (
-	"bufio"
-	"io"
+	"bytes"
+	"context"
 	"os"
 	"os/exec"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -17,6 +18,10 @@ type command struct {
 	Env    []string
 	Stdout func(string)
 	Stderr func(string)
+
+	// ProcessGroup runs the command in its own process group, on Unix, so
+	// that canceling it also kills the processes it started.
+	ProcessGroup bool
 }
 
 func makeCommand(cmd string, args ...string) *command {
@@ -53,56 +58,66 @@ func makeOutLog(fn func(...interface{})) func(string) {
 	return func(text string) { fn(text) }
 }
 
-func (c *command) Exec() error {
-	cmd := exec.Command(c.Path, c.Args...)
+// Exec runs the command and waits for it to finish. If ctx is done before the
+// command exits the command is killed, along with the processes it started if
+// ProcessGroup is set.
+func (c *command) Exec(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
 	cmd.Dir = c.Dir
+	if c.ProcessGroup {
+		killProcessGroup(cmd)
+	}
+	// Don't wait forever for output from processes that outlive the command.
+	cmd.WaitDelay = 10 * time.Second
 
 	if len(c.Env) > 0 {
 		cmd.Env = append(os.Environ(), c.Env...)
 	}
 
-	var err error
-	var stdout, stderr io.ReadCloser
+	// Output is copied by exec so that Wait returns only after all of it was
+	// passed to the callbacks.
+	var outputs []*lineWriter
 	if c.Stdout != nil {
-		stdout, err = cmd.StdoutPipe()
-		if err != nil {
-			return err
-		}
-		defer stdout.Close()
+		w := &lineWriter{fn: c.Stdout}
+		cmd.Stdout = w
+		outputs = append(outputs, w)
 	}
-
 	if c.Stderr != nil {
-		stderr, err = cmd.StderrPipe()
-		if err != nil {
-			return err
-		}
-		defer stderr.Close()
+		w := &lineWriter{fn: c.Stderr}
+		cmd.Stderr = w
+		outputs = append(outputs, w)
 	}
 
-	var wg sync.WaitGroup
-	defer wg.Wait()
-
-	captureLines := func(in io.Reader, fn func(string)) {
-		defer wg.Done()
-		scanner := bufio.NewScanner(in)
-		for scanner.Scan() {
-			text := scanner.Text()
-			fn(text)
-		}
+	err := cmd.Run()
+	for _, w := range outputs {
+		w.flush()
 	}
+	return err
+}
 
-	if stdout != nil {
-		wg.Add(1)
-		go captureLines(stdout, c.Stdout)
-	}
-	if stderr != nil {
-		wg.Add(1)
-		go captureLines(stderr, c.Stderr)
-	}
+// lineWriter calls fn for each line written to it.
+type lineWriter struct {
+	fn  func(string)
+	buf []byte
+}
 
-	if err := cmd.Start(); err != nil {
-		return err
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
 	}
+	return len(p), nil
+}
 
-	return cmd.Wait()
+// flush passes a final line without a newline to fn.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.fn(strings.TrimSuffix(string(w.buf), "\r"))
+		w.buf = nil
+	}
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -8,9 +9,9 @@ import (
 	"github.com/andrewkroh/gvm"
 )
 
-func availCommand(_ *kingpin.CmdClause) func(*gvm.Manager) error {
-	return func(manager *gvm.Manager) error {
-		list, err := manager.Available()
+func availCommand(_ *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		list, err := manager.AvailableContext(ctx)
 		if err != nil {
 			return err
 		}
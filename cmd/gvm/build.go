@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/andrewkroh/gvm"
+)
+
+func buildCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	var ref, fromDir, name string
+	cmd.Flag("ref", "Git branch, tag, commit, or Gerrit change ref (refs/changes/NN/NNNNNN/P) of the Go repository to build.").
+		StringVar(&ref)
+	cmd.Flag("from-dir", "Go source checkout to build, including uncommitted changes. Requires --name.").
+		StringVar(&fromDir)
+	cmd.Flag("name", "Name of the version to install the build as. Defaults to ref-<commit> with --ref.").StringVar(&name)
+
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		var build func() (*gvm.GoVersion, string, error)
+		switch {
+		case ref != "" && fromDir != "":
+			return errors.New("--ref and --from-dir cannot be used together")
+		case ref != "":
+			build = func() (*gvm.GoVersion, string, error) { return manager.BuildRefContext(ctx, ref, name) }
+		case fromDir != "":
+			if name == "" {
+				return errors.New("--from-dir requires --name")
+			}
+			build = func() (*gvm.GoVersion, string, error) { return manager.BuildDirContext(ctx, fromDir, name) }
+		default:
+			return errors.New("one of --ref or --from-dir is required")
+		}
+
+		defer withProgress(manager)()
+
+		fmt.Printf("Building %v. Please wait...\n", ref+fromDir)
+		ver, dir, err := build()
+		if err != nil {
+			fmt.Println("Build failed with:\n", err)
+			return err
+		}
+
+		info, err := manager.ReadBuildInfoContext(ctx, ver)
+		if err != nil {
+			return err
+		}
+		if info.Dir != "" {
+			fmt.Printf("Successfully built %v from %v to %v\n", ver, info.Dir, dir)
+		} else {
+			fmt.Printf("Successfully built %v (commit %v) to %v\n", ver, info.Commit, dir)
+		}
+		fmt.Printf("Use it with: gvm use %v\n", ver)
+		return nil
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"runtime"
 	"runtime/debug"
+	"syscall"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/sirupsen/logrus"
 
 	"github.com/andrewkroh/gvm"
+	"github.com/andrewkroh/gvm/common"
 )
 
 const usage = `gvm is a Go version manager. gvm installs a Go version and prints
@@ -51,14 +56,14 @@ func init() {
 	}
 }
 
-type commandFactory func(*kingpin.CmdClause) func(*gvm.Manager) error
+type commandFactory func(*kingpin.CmdClause) func(context.Context, *gvm.Manager) error
 
 func main() {
 	app := kingpin.New("gvm", usage)
 	debug := app.Flag("debug", "Enable debug logging to stderr.").Short('d').Bool()
 
-	manager := &gvm.Manager{}
-	commands := map[string]func(*gvm.Manager) error{}
+	manager := &gvm.Manager{Retry: common.DefaultRetryPolicy}
+	commands := map[string]func(context.Context, *gvm.Manager) error{}
 	command := func(factory commandFactory, name, doc string) *kingpin.CmdClause {
 		cmd := app.Command(name, doc)
 		act := factory(cmd)
@@ -67,22 +72,58 @@ func main() {
 		}
 		return cmd
 	}
+	subcommand := func(parent *kingpin.CmdClause, factory commandFactory, name, doc string) *kingpin.CmdClause {
+		cmd := parent.Command(name, doc)
+		act := factory(cmd)
+		if act != nil {
+			commands[cmd.FullCommand()] = act
+		}
+		return cmd
+	}
 
 	app.Flag("os", "Go binaries target os.").StringVar(&manager.GOOS)
 	app.Flag("arch", "Go binaries target architecture.").StringVar(&manager.GOARCH)
 	app.Flag("home", "GVM home directory.").StringVar(&manager.Home)
-	app.Flag("url", "Go binaries repository base URL.").StringVar(&manager.GoStorageHome)
+	app.Flag("url", "Go binaries repository base URL. May be a file:// URL or a local directory containing an index.json. A comma-separated list of mirrors is tried in order. Write a comma within a mirror URL as %2C.").StringVar(&manager.GoStorageHome)
 	app.Flag("repository", "Go upstream git repository.").StringVar(&manager.GoSourceURL)
+	app.Flag("source-filter", "git clone --filter of the source cache. Use none for a full clone, which can build any version offline.").
+		Default("blob:none").StringVar(&manager.SourceFilter)
+	app.Flag("bootstrap-goroot", "GOROOT of the Go toolchain used to build Go from source. Defaults to $GOROOT_BOOTSTRAP or a suitable gvm installed version.").
+		StringVar(&manager.BootstrapGOROOT)
 	app.Flag("http-timeout", "Timeout for HTTP requests.").Default("3m").DurationVar(&manager.HTTPTimeout)
+	httpOptions := registerHTTPFlags(app)
+	app.Flag("download-connections", "Number of concurrent range requests used to download an archive.").Default("1").IntVar(&manager.DownloadConnections)
+	app.Flag("retry-attempts", "Maximum number of attempts for each HTTP request. 1 disables retries.").Default("5").IntVar(&manager.Retry.MaxAttempts)
+	app.PreAction(func(*kingpin.ParseContext) error {
+		// The Manager replaces a retry policy without attempts with the
+		// default, which would drop the other retry flags.
+		if manager.Retry.MaxAttempts < 1 {
+			return fmt.Errorf("--retry-attempts must be at least 1, got %d", manager.Retry.MaxAttempts)
+		}
+		return nil
+	})
+	app.Flag("retry-delay", "Delay before the first retry. The delay doubles with each retry.").Default("1s").DurationVar(&manager.Retry.InitialDelay)
+	app.Flag("retry-max-elapsed", "Stop retrying an HTTP request after this much time. 0 means no limit.").Default("2m").DurationVar(&manager.Retry.MaxElapsed)
+	app.Flag("goproxy", "Install binary releases from the golang.org/toolchain module on the Go module proxy set by GOPROXY.").
+		BoolVar(&manager.UseModuleProxy)
+	app.Flag("lock-timeout", "How long to wait for another gvm process using the same version or cache. Negative values wait forever.").
+		Default("30m").DurationVar(&manager.LockTimeout)
+	app.Flag("offline", "Never access the network. Only use installed versions and cached data.").BoolVar(&manager.Offline)
+	app.Flag("index-ttl", "How long to use the cached release index before revalidating it.").Default("1h").DurationVar(&manager.ReleaseIndexTTL)
 
 	command(useCommand, "use", "prepare go version and print environment variables").
 		Default()
 	command(initCommand, "init", "init .gvm and update source cache")
 	command(installCommand, "install", "install go version if not already installed")
+	command(buildCommand, "build", "build a git ref or local checkout of the Go repository as a named version")
 	command(availCommand, "available", "list all installable go versions")
 	command(listCommand, "list", "list installed versions")
 	command(removeCommand, "remove", "remove a go version")
-	command(purgeCommand, "purge", "remove all but the newest go version")
+	command(purgeCommand, "purge", "remove all but the newest go version and prune cached archives")
+	command(logsCommand, "logs", "show the latest source build log of a go version or prune old logs")
+	command(serveCommand, "serve", "run a caching proxy of the Go downloads API for other gvm clients")
+	mirror := app.Command("mirror", "manage a directory mirror of Go release files")
+	subcommand(mirror, mirrorSyncCommand, "sync", "download release files into a mirror directory usable with --url")
 
 	app.Version(version)
 	app.HelpFlag.Short('h')
@@ -117,12 +158,23 @@ func main() {
 		os.Exit(2)
 	}
 
+	httpOptions.config.Timeout = manager.HTTPTimeout
+	if manager.HTTPClient, err = httpOptions.client(manager.GoStorageHome); err != nil {
+		app.Errorf("%v", err)
+		os.Exit(1)
+	}
+
 	if err := manager.Init(); err != nil {
 		app.Errorf("%v", err)
 		os.Exit(1)
 	}
 
-	if err := action(manager); err != nil {
+	// Cancel the running command on Ctrl-C or SIGTERM so that downloads and
+	// builds stop and clean up after themselves.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := action(ctx, manager); err != nil {
 		app.Errorf("%v", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// httpFlags holds the flags that configure the HTTP client.
+type httpFlags struct {
+	config    common.HTTPClientConfig
+	authHosts []string
+}
+
+func registerHTTPFlags(app *kingpin.Application) *httpFlags {
+	f := &httpFlags{}
+	app.Flag("http-proxy", "HTTP proxy URL. Defaults to the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.").StringVar(&f.config.Proxy)
+	app.Flag("ca-file", "PEM file of additional certificate authorities to trust.").ExistingFileVar(&f.config.CAFile)
+	app.Flag("client-cert", "PEM client certificate for TLS client authentication.").ExistingFileVar(&f.config.CertFile)
+	app.Flag("client-key", "PEM key of the client certificate.").ExistingFileVar(&f.config.KeyFile)
+	app.Flag("bearer-token", "Bearer token sent to the --auth-host servers.").StringVar(&f.config.BearerToken)
+	app.Flag("http-user", "Username for HTTP basic authentication with the --auth-host servers.").StringVar(&f.config.Username)
+	app.Flag("http-password", "Password for HTTP basic authentication with the --auth-host servers.").StringVar(&f.config.Password)
+	app.Flag("auth-host", "Host that --bearer-token or --http-user credentials are sent to. May be repeated. Defaults to the hosts of --url.").StringsVar(&f.authHosts)
+	app.Flag("netrc", "Use credentials from $NETRC or ~/.netrc for hosts without other credentials.").BoolVar(&f.config.Netrc)
+	app.Flag("user-agent", "User-Agent header sent with HTTP requests.").Default("gvm/" + version).StringVar(&f.config.UserAgent)
+	return f
+}
+
+// client returns an HTTP client configured by the flags. storageHome is the
+// --url value whose hosts credentials are sent to when no --auth-host is
+// given.
+func (f *httpFlags) client(storageHome string) (*http.Client, error) {
+	cfg := f.config
+	cfg.AuthHosts = f.authHosts
+	if len(cfg.AuthHosts) == 0 {
+		for _, home := range strings.Split(storageHome, ",") {
+			if u, err := url.Parse(strings.TrimSpace(home)); err == nil && u.Host != "" {
+				cfg.AuthHosts = append(cfg.AuthHosts, u.Host)
+			}
+		}
+	}
+	return common.NewHTTPClient(cfg)
+}
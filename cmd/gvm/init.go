@@ -1,13 +1,14 @@
 package main
 
 import (
+	"context"
 	"github.com/alecthomas/kingpin/v2"
 
 	"github.com/andrewkroh/gvm"
 )
 
-func initCommand(_ *kingpin.CmdClause) func(*gvm.Manager) error {
-	return func(manager *gvm.Manager) error {
-		return manager.UpdateCache()
+func initCommand(_ *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		return manager.UpdateCacheContext(ctx)
 	}
 }
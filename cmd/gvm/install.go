@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -8,44 +9,83 @@ import (
 	"github.com/andrewkroh/gvm"
 )
 
-func installCommand(cmd *kingpin.CmdClause) func(*gvm.Manager) error {
+func installCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
 	var version string
 	var build bool
+	var fromFile, fromURL, sha256 string
 	cmd.Flag("build", "Build go version from source").Short('b').BoolVar(&build)
-	cmd.Arg("version", "Go version to install (e.g. 1.24.0).").StringVar(&version)
+	cmd.Flag("from-file", "Install from a local Go binary distribution archive (.tar.gz or .zip).").
+		PlaceHolder("ARCHIVE").StringVar(&fromFile)
+	cmd.Flag("from-url", "Download and install a Go binary distribution archive from a URL.").
+		PlaceHolder("URL").StringVar(&fromURL)
+	cmd.Flag("sha256", "Expected SHA-256 hash of the archive given by --from-file or --from-url.").StringVar(&sha256)
+	cmd.Arg("version", "Go version to install (e.g. 1.24.0, 1.24, stable, oldstable, latest, 1.25rc, \">=1.23, <1.25\", ~1.24). "+
+		"Defaults to the version declared by .go-version, go.work, or go.mod.").StringVar(&version)
 
-	return func(manager *gvm.Manager) error {
-		if version == "" {
-			return fmt.Errorf("no version specified")
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		defer withProgress(manager)()
+
+		if fromFile != "" || fromURL != "" {
+			return installArchive(ctx, manager, fromFile, fromURL, sha256, version, build)
 		}
-		ver, err := gvm.ParseVersion(version)
+
+		ver, err := resolveVersion(ctx, manager, version)
 		if err != nil {
 			return err
 		}
 
-		has, err := manager.HasVersion(ver)
+		has, err := manager.HasVersionContext(ctx, ver)
 		if err != nil {
 			return err
 		}
 		if has {
-			fmt.Printf("Version %v already installed\n", version)
+			fmt.Printf("Version %v already installed\n", ver)
 			return nil
 		}
 
 		var dir string
 		if build {
-			fmt.Printf("Building go-%v. Please wait...\n", version)
-			dir, err = manager.Build(ver)
+			fmt.Printf("Building go-%v. Please wait...\n", ver)
+			dir, err = manager.BuildContext(ctx, ver)
 		} else {
-			fmt.Printf("Installing go-%v. Please wait...\n", version)
-			dir, err = manager.Install(ver)
+			fmt.Printf("Installing go-%v. Please wait...\n", ver)
+			dir, err = manager.InstallContext(ctx, ver)
 		}
 		if err != nil {
 			fmt.Println("Installation failed with:\n", err)
 			return err
 		}
 
-		fmt.Printf("Successfully installed go-%v to %v\n", version, dir)
+		fmt.Printf("Successfully installed go-%v to %v\n", ver, dir)
 		return nil
 	}
 }
+
+func installArchive(ctx context.Context, manager *gvm.Manager, fromFile, fromURL, sha256, version string, build bool) error {
+	switch {
+	case fromFile != "" && fromURL != "":
+		return fmt.Errorf("--from-file and --from-url cannot be used together")
+	case version != "":
+		return fmt.Errorf("a version cannot be given with --from-file or --from-url, it is read from the archive")
+	case build:
+		return fmt.Errorf("--build cannot be used with --from-file or --from-url")
+	}
+
+	var ver *gvm.GoVersion
+	var dir string
+	var err error
+	if fromFile != "" {
+		fmt.Printf("Installing %v. Please wait...\n", fromFile)
+		ver, dir, err = manager.InstallArchiveContext(ctx, fromFile, sha256)
+	} else {
+		fmt.Printf("Installing %v. Please wait...\n", fromURL)
+		ver, dir, err = manager.InstallURLContext(ctx, fromURL, sha256)
+	}
+	if err != nil {
+		fmt.Println("Installation failed with:\n", err)
+		return err
+	}
+
+	fmt.Printf("Successfully installed go-%v to %v\n", ver, dir)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -8,9 +9,9 @@ import (
 	"github.com/andrewkroh/gvm"
 )
 
-func listCommand(_ *kingpin.CmdClause) func(*gvm.Manager) error {
-	return func(manager *gvm.Manager) error {
-		versions, err := manager.Installed()
+func listCommand(_ *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		versions, err := manager.InstalledContext(ctx)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/andrewkroh/gvm"
+)
+
+func logsCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	var (
+		version string
+		path    bool
+		prune   bool
+		maxAge  time.Duration
+	)
+	cmd.Arg("version", "Go version whose latest source build log is shown.").StringVar(&version)
+	cmd.Flag("path", "Print the path of the build log instead of its contents.").BoolVar(&path)
+	cmd.Flag("prune", "Remove build logs older than --max-age. The newest log of each version is kept.").BoolVar(&prune)
+	cmd.Flag("max-age", "Age of the build logs removed by --prune.").Default("168h").DurationVar(&maxAge)
+
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		if prune {
+			removed, err := manager.PruneBuildLogsContext(ctx, maxAge)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d build logs\n", len(removed))
+			if version == "" {
+				return nil
+			}
+		}
+
+		if version == "" {
+			return errors.New("no version specified")
+		}
+		ver, err := gvm.ParseVersion(version)
+		if err != nil {
+			if ver, err = gvm.NamedVersion(version); err != nil {
+				return fmt.Errorf("invalid version '%v': %w", version, err)
+			}
+		}
+
+		logFile, err := manager.LatestBuildLogContext(ctx, ver)
+		if err != nil {
+			return err
+		}
+		if path {
+			fmt.Println(logFile)
+			return nil
+		}
+
+		f, err := os.Open(logFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(os.Stdout, f)
+		return err
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/andrewkroh/gvm"
+)
+
+func mirrorSyncCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	var dir string
+	opts := gvm.MirrorOptions{
+		Progress: func(filename string) { fmt.Printf("Downloading %v...\n", filename) },
+	}
+	cmd.Arg("dir", "Mirror directory.").Required().StringVar(&dir)
+	cmd.Arg("versions", "Go versions to mirror (e.g. 1.24.0, stable, \">=1.23\").").Required().StringsVar(&opts.Versions)
+	cmd.Flag("platform", "GOOS/GOARCH to mirror (e.g. linux/amd64, linux/*). May be repeated. "+
+		"Defaults to --os/--arch.").StringsVar(&opts.Platforms)
+	cmd.Flag("kind", "Kind of file to mirror (archive, installer, source). May be repeated.").
+		Default("archive").StringsVar(&opts.Kinds)
+
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		result, err := manager.MirrorSyncContext(ctx, dir, opts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Mirror %v updated: %d downloaded, %d up to date\n",
+			dir, len(result.Downloaded), len(result.UpToDate))
+		return nil
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewkroh/gvm"
+)
+
+// progressPrinter renders gvm.ProgressEvents. On a terminal it redraws a
+// single status line with a progress bar. Otherwise it prints a line when a
+// phase starts and then at most once per interval.
+type progressPrinter struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+
+	mu       sync.Mutex
+	key      string    // Phase and file (or step) of the last event.
+	last     time.Time // Time the last line was printed.
+	lineOpen bool      // A status line was drawn without a trailing newline.
+}
+
+func newProgressPrinter(f *os.File) *progressPrinter {
+	p := &progressPrinter{out: f, interval: 5 * time.Second}
+	if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		p.tty = true
+		p.interval = 100 * time.Millisecond
+	}
+	return p
+}
+
+// withProgress reports the Manager's progress to stderr until the returned
+// function is called.
+func withProgress(manager *gvm.Manager) func() {
+	p := newProgressPrinter(os.Stderr)
+	manager.Progress = p.Report
+	return func() {
+		manager.Progress = nil
+		p.Done()
+	}
+}
+
+// Report renders an event.
+func (p *progressPrinter) Report(ev gvm.ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := string(ev.Phase) + ":" + ev.File + ev.Step
+	now := time.Now()
+	complete := ev.Total > 0 && ev.Current >= ev.Total
+	if key == p.key && !complete && now.Sub(p.last) < p.interval {
+		return
+	}
+	if key != p.key && p.lineOpen {
+		fmt.Fprintln(p.out)
+		p.lineOpen = false
+	}
+	p.key = key
+	p.last = now
+
+	line := formatProgress(ev, p.tty)
+	if p.tty && ev.Phase != gvm.PhaseBuild {
+		fmt.Fprintf(p.out, "\r\033[K%s", line)
+		p.lineOpen = true
+		return
+	}
+	fmt.Fprintln(p.out, line)
+}
+
+// Done ends the status line, if any.
+func (p *progressPrinter) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.lineOpen {
+		fmt.Fprintln(p.out)
+		p.lineOpen = false
+	}
+	p.key = ""
+}
+
+const progressBarWidth = 30
+
+func formatProgress(ev gvm.ProgressEvent, bar bool) string {
+	switch ev.Phase {
+	case gvm.PhaseDownload:
+		if ev.Total <= 0 {
+			return fmt.Sprintf("Downloading %v %v", ev.File, formatBytes(ev.Current))
+		}
+		pct := float64(ev.Current) / float64(ev.Total)
+		if !bar {
+			return fmt.Sprintf("Downloading %v %3.0f%% (%v of %v)", ev.File, 100*pct, formatBytes(ev.Current), formatBytes(ev.Total))
+		}
+		filled := int(pct * progressBarWidth)
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+		return fmt.Sprintf("Downloading %v [%s%s] %3.0f%% %v/%v", ev.File,
+			strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+			100*pct, formatBytes(ev.Current), formatBytes(ev.Total))
+	case gvm.PhaseExtract:
+		return fmt.Sprintf("Extracting %v %d files", ev.File, ev.Current)
+	case gvm.PhaseBuild:
+		return ev.Step
+	default:
+		return fmt.Sprintf("%v %v", ev.Phase, ev.File)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/andrewkroh/gvm"
+)
+
+func TestProgressPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progressPrinter{out: &buf, interval: 1 << 62}
+
+	const file = "go1.22.5.linux-amd64.tar.gz"
+	p.Report(gvm.ProgressEvent{Phase: gvm.PhaseDownload, File: file, Current: 1 << 20, Total: 4 << 20})
+	// Throttled until the interval passes.
+	p.Report(gvm.ProgressEvent{Phase: gvm.PhaseDownload, File: file, Current: 2 << 20, Total: 4 << 20})
+	p.Report(gvm.ProgressEvent{Phase: gvm.PhaseDownload, File: file, Current: 4 << 20, Total: 4 << 20})
+	p.Report(gvm.ProgressEvent{Phase: gvm.PhaseExtract, File: file, Current: 1, Total: -1})
+	p.Report(gvm.ProgressEvent{Phase: gvm.PhaseBuild, Step: "Building Go toolchain1", Total: -1})
+	p.Done()
+
+	assert.Equal(t, "Downloading "+file+"  25% (1.0 MiB of 4.0 MiB)\n"+
+		"Downloading "+file+" 100% (4.0 MiB of 4.0 MiB)\n"+
+		"Extracting "+file+" 1 files\n"+
+		"Building Go toolchain1\n", buf.String())
+}
+
+func TestFormatProgressBar(t *testing.T) {
+	line := formatProgress(gvm.ProgressEvent{Phase: gvm.PhaseDownload, File: "go.zip", Current: 512, Total: 1024}, true)
+	assert.Equal(t, "Downloading go.zip [===============               ]  50% 512 B/1.0 KiB", line)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -8,13 +9,21 @@ import (
 	"github.com/andrewkroh/gvm"
 )
 
-func purgeCommand(_ *kingpin.CmdClause) func(*gvm.Manager) error {
-	return func(manager *gvm.Manager) error {
-		versions, err := manager.Installed()
+func purgeCommand(_ *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		installed, err := manager.InstalledContext(ctx)
 		if err != nil {
 			return err
 		}
 
+		// Named versions are never purged.
+		var versions []*gvm.GoVersion
+		for _, v := range installed {
+			if !v.IsNamed() {
+				versions = append(versions, v)
+			}
+		}
+
 		// find installed highest stable release
 		stable := -1
 		for i := len(versions) - 1; i != -1; i-- {
@@ -27,19 +36,23 @@ func purgeCommand(_ *kingpin.CmdClause) func(*gvm.Manager) error {
 		if stable <= 0 {
 			fmt.Println("No versions to remove")
 		} else {
-			removeVersions(manager, versions[:stable])
+			removeVersions(ctx, manager, versions[:stable])
 
 			// unstable versions > last stable version
 			versions = versions[stable+1:]
 		}
 
-		if len(versions) <= 1 {
-			return nil
+		if len(versions) > 1 {
+			// remove all but highest unstable version
+			removeVersions(ctx, manager, versions[:len(versions)-1])
 		}
 
-		// remove all but highest unstable version
-		removeVersions(manager, versions[:len(versions)-1])
-
+		// Cached archives are only kept to reinstall installed versions.
+		removed, err := manager.PruneArchivesContext(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d cached archives\n", len(removed))
 		return nil
 	}
 }
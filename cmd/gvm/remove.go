@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -8,11 +9,11 @@ import (
 	"github.com/andrewkroh/gvm"
 )
 
-func removeCommand(cmd *kingpin.CmdClause) func(*gvm.Manager) error {
+func removeCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
 	var versions []string
 	cmd.Arg("versions", "Go versions to remove").StringsVar(&versions)
 
-	return func(manager *gvm.Manager) error {
+	return func(ctx context.Context, manager *gvm.Manager) error {
 		if len(versions) == 0 {
 			return fmt.Errorf("no versions specified")
 		}
@@ -21,21 +22,23 @@ func removeCommand(cmd *kingpin.CmdClause) func(*gvm.Manager) error {
 		for _, version := range versions {
 			ver, err := gvm.ParseVersion(version)
 			if err != nil {
-				fmt.Printf("Invalid version '%v': %v\n", version, err)
-				continue
+				if ver, err = gvm.NamedVersion(version); err != nil {
+					fmt.Printf("Invalid version '%v': %v\n", version, err)
+					continue
+				}
 			}
 			list = append(list, ver)
 		}
 
-		removeVersions(manager, list)
+		removeVersions(ctx, manager, list)
 		return nil
 	}
 }
 
-func removeVersions(manager *gvm.Manager, versions []*gvm.GoVersion) {
+func removeVersions(ctx context.Context, manager *gvm.Manager, versions []*gvm.GoVersion) {
 	for _, version := range versions {
 		fmt.Printf("Removing version %v...\n", version)
-		if err := manager.Remove(version); err != nil {
+		if err := manager.RemoveContext(ctx, version); err != nil {
 			fmt.Printf("Can not remove verions %v:\n%v\n", version, err)
 		} else {
 			fmt.Println("Removed version", version)
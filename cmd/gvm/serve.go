@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/alecthomas/units"
+
+	"github.com/andrewkroh/gvm"
+)
+
+func serveCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
+	var listen, cacheDir string
+	var maxCacheSize units.Base2Bytes
+	cmd.Flag("listen", "Address to listen on.").Default("localhost:8080").StringVar(&listen)
+	cmd.Flag("cache-dir", "Directory for cached archives. Defaults to a directory in the gvm home.").StringVar(&cacheDir)
+	cmd.Flag("max-cache-size", "Maximum total size of cached archives. Use 0 for no limit.").
+		Default("10GB").BytesVar(&maxCacheSize)
+
+	return func(ctx context.Context, manager *gvm.Manager) error {
+		handler, err := manager.NewCacheServer(cacheDir, int64(maxCacheSize))
+		if err != nil {
+			return err
+		}
+
+		srv := &http.Server{
+			Addr:              listen,
+			Handler:           handler,
+			ReadHeaderTimeout: 30 * time.Second,
+		}
+
+		// Stop accepting connections and let in-flight requests finish when
+		// the command is interrupted.
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("Serving Go releases from %v on http://%v\n", manager.GoStorageHome, listen)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -17,10 +20,11 @@ type useCmd struct {
 	format    string // Shell command format to output.
 }
 
-func useCommand(cmd *kingpin.CmdClause) func(*gvm.Manager) error {
+func useCommand(cmd *kingpin.CmdClause) func(context.Context, *gvm.Manager) error {
 	ctx := &useCmd{}
 
-	cmd.Arg("version", "Go version to install (e.g. 1.24.0).").StringVar(&ctx.version)
+	cmd.Arg("version", "Go version to install (e.g. 1.24.0, 1.24, stable, oldstable, latest, 1.25rc, \">=1.23, <1.25\", ~1.24). "+
+		"Defaults to the version declared by .go-version, go.work, or go.mod.").StringVar(&ctx.version)
 	cmd.Flag("build", "Build go version from source").Short('b').BoolVar(&ctx.build)
 	cmd.Flag("no-install", "Don't install if missing").Short('n').BoolVar(&ctx.noInstall)
 	cmd.Flag("format", "Format to use for the shell commands. Options: bash, batch, powershell").
@@ -31,11 +35,8 @@ func useCommand(cmd *kingpin.CmdClause) func(*gvm.Manager) error {
 	return ctx.Run
 }
 
-func (cmd *useCmd) Run(manager *gvm.Manager) error {
-	if cmd.version == "" {
-		return fmt.Errorf("no version specified")
-	}
-	ver, err := gvm.ParseVersion(cmd.version)
+func (cmd *useCmd) Run(ctx context.Context, manager *gvm.Manager) error {
+	ver, err := resolveVersion(ctx, manager, cmd.version)
 	if err != nil {
 		return err
 	}
@@ -46,11 +47,13 @@ func (cmd *useCmd) Run(manager *gvm.Manager) error {
 		return err
 	}
 
+	defer withProgress(manager)()
+
 	var goroot string
 	if cmd.build {
-		goroot, err = manager.Build(ver)
+		goroot, err = manager.BuildContext(ctx, ver)
 	} else if cmd.noInstall {
-		has, err := manager.HasVersion(ver)
+		has, err := manager.HasVersionContext(ctx, ver)
 		if err != nil {
 			return err
 		}
@@ -59,7 +62,7 @@ func (cmd *useCmd) Run(manager *gvm.Manager) error {
 		}
 		goroot = manager.VersionGoROOT(ver)
 	} else {
-		goroot, err = manager.Install(ver)
+		goroot, err = manager.InstallContext(ctx, ver)
 	}
 	if err != nil {
 		return err
@@ -73,3 +76,27 @@ func (cmd *useCmd) Run(manager *gvm.Manager) error {
 
 	return nil
 }
+
+// resolveVersion resolves the version specifier given on the command line. If
+// no version was given then the version declared by the project in the
+// working directory is used.
+func resolveVersion(ctx context.Context, manager *gvm.Manager, version string) (*gvm.GoVersion, error) {
+	if version == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+
+		var file string
+		version, file, err = gvm.FindProjectVersion(wd)
+		if err != nil {
+			if errors.Is(err, gvm.ErrNoVersionFile) {
+				return nil, fmt.Errorf("no version specified and %w", err)
+			}
+			return nil, err
+		}
+		log.Debugf("Found Go version %v in %v", version, file)
+	}
+
+	return manager.ResolveVersionContext(ctx, version)
+}
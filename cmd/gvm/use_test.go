@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"go/build"
@@ -27,7 +28,8 @@ var (
 )
 
 func TestGVMRunUse(t *testing.T) {
-	// When testing building from source GOROOT_BOOTSTRAP must be set.
+	// Bootstrap source builds with the Go running the tests rather than
+	// installing a bootstrap toolchain.
 	t.Setenv("GOROOT_BOOTSTRAP", build.Default.GOROOT)
 
 	cases := []struct {
@@ -89,7 +91,7 @@ func TestGVMRunUse(t *testing.T) {
 					format:  tc.Format,
 					build:   tc.FromSource,
 				}
-				err := cmd.Run(manager)
+				err := cmd.Run(context.Background(), manager)
 				if err != nil {
 					t.Fatal(err)
 				}
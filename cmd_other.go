@@ -0,0 +1,9 @@
+//go:build !unix
+
+package gvm
+
+import "os/exec"
+
+// killProcessGroup is a no-op. When the command's context is done only the
+// command itself is killed.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package gvm
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup runs the command in its own process group and kills the
+// whole group when the command's context is done. This stops the compilers
+// and tools started by make.bash along with the script itself.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
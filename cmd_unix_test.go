@@ -0,0 +1,47 @@
+//go:build unix
+
+package gvm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandExecCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The background sleep inherits stdout. It must be killed along with the
+	// shell for Exec to return before WaitDelay.
+	cmd := makeCommand("sh", "-c", "sleep 30 & wait")
+	cmd.ProcessGroup = true
+	cmd.Stdout = func(string) {}
+
+	start := time.Now()
+	err := cmd.Exec(ctx)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCommandExecCapturesAllOutput(t *testing.T) {
+	// The process exits right after writing its output. Exec must not return
+	// before all of it, including a final line without a newline, was passed
+	// to Stdout.
+	for i := 0; i < 20; i++ {
+		var lines int
+		var last string
+		cmd := makeCommand("sh", "-c", "seq 1 20000; printf done")
+		cmd.Stdout = func(line string) {
+			lines++
+			last = line
+		}
+
+		require.NoError(t, cmd.Exec(context.Background()))
+		assert.Equal(t, 20001, lines)
+		assert.Equal(t, "done", last)
+	}
+}
@@ -1,12 +1,18 @@
 package common
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/otiai10/copy"
@@ -18,64 +24,478 @@ var log = logrus.WithField("package", "common")
 // ErrNotFound is returned when the download fails due to HTTP 404 Not Found.
 var ErrNotFound = errors.New("not found")
 
-type retryParams struct {
-	maxRetries int
-	retryDelay time.Duration
+// DownloadOptions configures DownloadFileWithOptions.
+type DownloadOptions struct {
+	// HTTPTimeout is the timeout of each HTTP request. It is ignored if
+	// Client is set.
+	HTTPTimeout time.Duration
+
+	// Client is used to make HTTP requests. If nil a client with
+	// HTTPTimeout is used.
+	Client *http.Client
+
+	// Retry controls how failed downloads are retried. Progress is kept
+	// between attempts.
+	Retry RetryPolicy
+
+	// Connections is the number of concurrent range requests used to fetch a
+	// file when the server advertises Accept-Ranges. Values <= 1 download the
+	// file as a single stream.
+	Connections int
+
+	// Progress, if not nil, is called as data is received with the number of
+	// bytes downloaded and the size of the file (-1 if unknown). Bytes kept
+	// from an earlier attempt count as downloaded.
+	Progress func(downloaded, total int64)
 }
 
-var DefaultRetryParams = retryParams{
-	maxRetries: 5,
-	retryDelay: 10 * time.Second,
+// minChunkSize is the smallest part of a file fetched by a parallel download.
+// Smaller files are downloaded as a single stream.
+var minChunkSize int64 = 4 << 20
+
+// DownloadFile downloads the file at url into destinationDir and returns its
+// path. file:// URLs are copied from the local filesystem. It returns
+// ErrNotFound if the file does not exist.
+func DownloadFile(url, destinationDir string, httpTimeout time.Duration, r RetryPolicy) (string, error) {
+	return DownloadFileWithOptions(context.Background(), url, destinationDir, DownloadOptions{HTTPTimeout: httpTimeout, Retry: r})
 }
 
-func DownloadFile(url, destinationDir string, httpTimeout time.Duration, r retryParams) (string, error) {
+// DownloadFileWithOptions downloads the file at url into destinationDir and
+// returns its path. The data is written to a .part file (.chunks for parallel
+// downloads) that is renamed once the download is complete. A failed download
+// resumes with Range requests, including in later calls for the same URL and
+// directory. Resumes send If-Range with the ETag or Last-Modified time of the
+// first response, saved in a .state file, and start over if the file on the
+// server changed. Downloads from servers that send neither are not resumed. The size of the file is checked against the Content-Length
+// returned by the server. Canceling ctx aborts the download and any wait
+// between retries.
+func DownloadFileWithOptions(ctx context.Context, url, destinationDir string, opts DownloadOptions) (string, error) {
+	if path, ok := FileURLPath(url); ok {
+		log.WithField("path", path).Debug("Copying file")
+		return copyFile(path, destinationDir)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: opts.HTTPTimeout}
+	}
+
 	log.WithField("url", url).Debug("Downloading file")
-	var name string
-	var err error
-	var retry bool
-
-	for a := 1; a <= r.maxRetries; a++ {
-		name, retry, err = downloadFile(url, destinationDir, httpTimeout)
-		if err != nil && retry {
-			log.WithError(err).Debugf("Download attempt %d/%d failed, retrying in %s", a, r.maxRetries, r.retryDelay)
-			time.Sleep(r.retryDelay)
-			continue
+	d := &download{
+		ctx:      ctx,
+		url:      url,
+		name:     filepath.Join(destinationDir, downloadFileName(url)),
+		client:   client,
+		size:     -1,
+		progress: opts.Progress,
+	}
+
+	if opts.Connections > 1 {
+		if err := d.planChunks(opts.Connections); err != nil {
+			log.WithError(err).Debug("Falling back to a single stream download")
+		}
+	}
+
+	err := opts.Retry.Do(ctx, func() (bool, error) {
+		if d.chunks != nil {
+			return d.fetchChunks()
+		}
+		return d.fetch()
+	})
+	if err != nil {
+		if d.validator == "" {
+			// Without a validator the download cannot be resumed safely.
+			os.Remove(d.partFile())
+			os.Remove(d.chunksFile())
 		}
-		break
+		return "", err
 	}
-	return name, err
+	os.Remove(d.stateFile())
+	return d.name, nil
+}
+
+// download is the state of a file download that is kept between attempts.
+type download struct {
+	ctx    context.Context
+	url    string
+	name   string
+	client *http.Client
+	size   int64    // Size of the file or -1 if unknown.
+	chunks []*chunk // Parts of the file fetched in parallel.
+
+	// validator is the strong ETag or the Last-Modified time of the file,
+	// sent in If-Range when resuming. Empty if the server sent neither.
+	validator string
+
+	progress   func(downloaded, total int64)
+	progressMu sync.Mutex // Serializes progress reports from parallel chunks.
+	downloaded int64      // Bytes downloaded, guarded by progressMu.
 }
 
-func downloadFile(url, destinationDir string, httpTimeout time.Duration) (path string, retryable bool, err error) {
-	client := http.Client{
-		Timeout: httpTimeout,
+// addProgress records n downloaded bytes and reports the progress.
+func (d *download) addProgress(n int64) {
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+	d.downloaded += n
+	if d.progress != nil {
+		d.progress(d.downloaded, d.size)
 	}
-	resp, err := client.Get(url)
+}
+
+// setProgress sets the number of downloaded bytes, e.g. when a download is
+// resumed or restarted.
+func (d *download) setProgress(n int64) {
+	d.progressMu.Lock()
+	d.downloaded = 0
+	d.progressMu.Unlock()
+	d.addProgress(n)
+}
+
+// chunk is a byte range [Start, End) of a parallel download.
+type chunk struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"` // Number of bytes written.
+}
+
+// downloadState is saved next to an incomplete download so that later calls
+// resume it only if the file on the server did not change.
+type downloadState struct {
+	Validator string   `json:"validator"`
+	Size      int64    `json:"size"`
+	Chunks    []*chunk `json:"chunks,omitempty"`
+}
+
+func (d *download) partFile() string   { return d.name + ".part" }
+func (d *download) chunksFile() string { return d.name + ".chunks" }
+func (d *download) stateFile() string  { return d.name + ".state" }
+
+// loadState reads the state saved by an earlier call.
+func (d *download) loadState() (*downloadState, error) {
+	data, err := os.ReadFile(d.stateFile())
 	if err != nil {
-		return "", true, fmt.Errorf("http get failed: %w", err)
+		return nil, err
+	}
+	var st downloadState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// saveState saves the validator and chunk progress for later calls. Failures
+// are only logged because they just prevent resuming.
+func (d *download) saveState() {
+	if d.validator == "" {
+		os.Remove(d.stateFile())
+		return
+	}
+	data, err := json.Marshal(downloadState{Validator: d.validator, Size: d.size, Chunks: d.chunks})
+	if err == nil {
+		err = os.WriteFile(d.stateFile(), data, 0o644)
+	}
+	if err != nil {
+		log.WithError(err).WithField("file", d.name).Debug("Failed to save download state")
+	}
+}
+
+// restart discards the partial download after the file on the server
+// changed. The next attempt downloads it as a single stream.
+func (d *download) restart() {
+	d.chunks = nil
+	d.size = -1
+	d.validator = ""
+	os.Remove(d.partFile())
+	os.Remove(d.chunksFile())
+	os.Remove(d.stateFile())
+}
+
+// errFileChanged is returned when the server answers a resumed request with
+// the whole file because it no longer matches If-Range.
+var errFileChanged = errors.New("file changed on the server")
+
+// resumeValidator returns the strong ETag of the response, or else its
+// Last-Modified time, for use in If-Range.
+func resumeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// fetch downloads the file as a single stream, resuming from the .part file
+// if it exists.
+func (d *download) fetch() (retryable bool, err error) {
+	part := d.partFile()
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 && d.validator == "" {
+		if st, err := d.loadState(); err == nil {
+			d.validator = st.Validator
+		}
+	}
+	if offset > 0 && d.validator == "" {
+		// A changed file cannot be detected without a validator, so the
+		// partial file might be a prefix of a different file.
+		os.Remove(part)
+		offset = 0
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", d.validator)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("http get failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The server ignored the range or the file changed so start over.
+		flags |= os.O_TRUNC
+		offset = 0
+		d.size = resp.ContentLength
+		d.validator = resumeValidator(resp.Header)
+		d.saveState()
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			os.Remove(part)
+			return true, fmt.Errorf("unexpected Content-Range %q when resuming at byte %d", resp.Header.Get("Content-Range"), offset)
+		}
+		log.WithFields(logrus.Fields{"file": d.name, "offset": offset}).Debug("Resuming download")
+		flags |= os.O_APPEND
+		d.size = total
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is not a prefix of the file on the server.
+		os.Remove(part)
+		return true, fmt.Errorf("cannot resume download at byte %d", offset)
+	case http.StatusNotFound:
+		return false, ErrNotFound
+	default:
+		statusErr := newStatusError(resp)
+		return statusErr.Retryable(), statusErr
+	}
+
+	f, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	d.setProgress(offset)
+	numBytes, err := io.Copy(&progressWriter{w: f, d: d}, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return true, fmt.Errorf("failed to write file to disk: %w", err)
+	}
+	if d.size >= 0 && offset+numBytes != d.size {
+		return true, fmt.Errorf("download incomplete: got %d of %d bytes", offset+numBytes, d.size)
+	}
+
+	if err := os.Rename(part, d.name); err != nil {
+		return false, err
+	}
+	log.WithFields(logrus.Fields{"file": d.name, "size_bytes": offset + numBytes}).Debug("Download complete")
+	return false, nil
+}
+
+// planChunks splits the file into n chunks if the server supports range
+// requests and the file is large enough.
+func (d *download) planChunks(n int) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodHead, d.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return "", false, ErrNotFound
+		return fmt.Errorf("http head failed with status %v", resp.StatusCode)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return errors.New("server does not support range requests")
+	}
+	size := resp.ContentLength
+	if size < 2*minChunkSize {
+		return fmt.Errorf("file size %d is too small for a parallel download", size)
+	}
+
+	d.size = size
+	d.validator = resumeValidator(resp.Header)
+	if st, err := d.loadState(); err == nil && d.validator != "" && st.Validator == d.validator && st.Size == size && len(st.Chunks) > 0 {
+		if info, err := os.Stat(d.chunksFile()); err == nil && info.Size() == size {
+			log.WithField("file", d.name).Debug("Resuming parallel download")
+			d.chunks = st.Chunks
+			return nil
+		}
+	}
+
+	chunkSize := size / int64(n)
+	if chunkSize < minChunkSize {
+		chunkSize = minChunkSize
+	}
+	for start := int64(0); start < size; start += chunkSize {
+		end := start + chunkSize
+		if end > size || size-end < minChunkSize {
+			end = size
+		}
+		d.chunks = append(d.chunks, &chunk{Start: start, End: end})
+		if end == size {
+			break
 		}
-		return "", true, fmt.Errorf("download failed with http status %v", resp.StatusCode)
 	}
+	return nil
+}
 
-	name := filepath.Join(destinationDir, filepath.Base(url))
-	f, err := os.Create(name)
+// fetchChunks downloads the incomplete chunks concurrently.
+func (d *download) fetchChunks() (retryable bool, err error) {
+	f, err := os.OpenFile(d.chunksFile(), os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to create output file: %w", err)
+		return false, fmt.Errorf("failed to create output file: %w", err)
 	}
+	if err := f.Truncate(d.size); err != nil {
+		f.Close()
+		return false, err
+	}
+
+	var done int64
+	for _, c := range d.chunks {
+		done += c.Done
+	}
+	d.setProgress(done)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(d.chunks))
+	for i, c := range d.chunks {
+		if c.Done == c.End-c.Start {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c *chunk) {
+			defer wg.Done()
+			errs[i] = d.fetchChunk(f, c)
+		}(i, c)
+	}
+	wg.Wait()
 
-	numBytes, err := io.Copy(f, resp.Body)
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		if errors.Is(err, errFileChanged) {
+			d.restart()
+			return true, err
+		}
+		d.saveState()
+		if errors.Is(err, ErrNotFound) {
+			return false, ErrNotFound
+		}
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.Retryable() {
+			return false, err
+		}
+		return true, err
+	}
+
+	if err := os.Rename(d.chunksFile(), d.name); err != nil {
+		return false, err
+	}
+	log.WithFields(logrus.Fields{"file": d.name, "size_bytes": d.size, "chunks": len(d.chunks)}).Debug("Download complete")
+	return false, nil
+}
+
+func (d *download) fetchChunk(f *os.File, c *chunk) error {
+	start := c.Start + c.Done
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.url, nil)
 	if err != nil {
-		return "", true, fmt.Errorf("failed to write file to disk: %w", err)
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, c.End-1))
+	if d.validator != "" {
+		req.Header.Set("If-Range", d.validator)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http get failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return errFileChanged
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return newStatusError(resp)
+	}
+	if got, total, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok || got != start || total != d.size {
+		return fmt.Errorf("unexpected Content-Range %q for byte %d", resp.Header.Get("Content-Range"), start)
 	}
-	log.WithFields(logrus.Fields{"file": name, "size_bytes": numBytes}).Debug("Download complete")
 
-	return name, false, nil
+	w := &progressWriter{w: io.NewOffsetWriter(f, start), d: d, n: &c.Done}
+	if _, err := io.Copy(w, io.LimitReader(resp.Body, c.End-start)); err != nil {
+		return fmt.Errorf("failed to write file to disk: %w", err)
+	}
+	if c.Done != c.End-c.Start {
+		return fmt.Errorf("chunk incomplete: got %d of %d bytes", c.Done, c.End-c.Start)
+	}
+	return nil
+}
+
+// progressWriter reports the bytes written to the download's progress and
+// adds them to n if it is not nil.
+type progressWriter struct {
+	w io.Writer
+	d *download
+	n *int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if w.n != nil {
+		*w.n += int64(n)
+	}
+	w.d.addProgress(int64(n))
+	return n, err
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes start-end/total". total is -1 if the size is unknown.
+func parseContentRange(v string) (start, total int64, ok bool) {
+	var end int64
+	if _, err := fmt.Sscanf(v, "bytes %d-%d/%d", &start, &end, &total); err == nil {
+		return start, total, true
+	}
+	if _, err := fmt.Sscanf(v, "bytes %d-%d/*", &start, &end); err == nil {
+		return start, -1, true
+	}
+	return 0, 0, false
+}
+
+// downloadFileName returns the name of the file referenced by the URL, ignoring
+// any query string or fragment.
+func downloadFileName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return filepath.Base(rawURL)
 }
 
 // Rename renames src to dest. If the rename operation fails it will attempt to
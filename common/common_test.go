@@ -0,0 +1,237 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3}
+
+func testContent(size int) []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), size/16)
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	content := testContent(1 << 16)
+	var requests atomic.Int32
+	var resumedAt string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if requests.Add(1) == 1 {
+			// Fail half way through the first response.
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(content[:len(content)/2])
+			panic(http.ErrAbortHandler)
+		}
+		resumedAt = r.Header.Get("Range")
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path, err := DownloadFile(srv.URL+"/go.tar.gz", dir, time.Minute, testRetryPolicy)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "go.tar.gz"), path)
+	assert.EqualValues(t, 2, requests.Load())
+	assert.Equal(t, "bytes=32768-", resumedAt)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.NoFileExists(t, path+".part")
+}
+
+func TestDownloadFileNoValidator(t *testing.T) {
+	content := testContent(1 << 16)
+	var requests atomic.Int32
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(content[:len(content)/2])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	// Without an ETag or Last-Modified the partial file is discarded.
+	dir := t.TempDir()
+	_, err := DownloadFile(srv.URL+"/go.tar.gz", dir, time.Minute, RetryPolicy{MaxAttempts: 1})
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "go.tar.gz.part"))
+
+	// A stale partial file is not resumed either.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.tar.gz.part"), []byte("stale"), 0o644))
+	path, err := DownloadFile(srv.URL+"/go.tar.gz", dir, time.Minute, testRetryPolicy)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", ""}, ranges)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestDownloadFileIncomplete(t *testing.T) {
+	content := testContent(1 << 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2048")
+		_, _ = w.Write(content)
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	_, err := DownloadFile(srv.URL+"/go.tar.gz", dir, time.Minute, RetryPolicy{MaxAttempts: 1})
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "go.tar.gz"))
+}
+
+func TestDownloadFileParallel(t *testing.T) {
+	defer func(size int64) { minChunkSize = size }(minChunkSize)
+	minChunkSize = 1 << 10
+
+	content := testContent(1 << 14)
+	var ranges atomic.Int32
+	var failed atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges.Add(1)
+			// Fail one of the chunks once.
+			if strings.HasPrefix(r.Header.Get("Range"), "bytes=4096-") && !failed.Swap(true) {
+				w.Header().Set("Content-Range", "bytes 4096-8191/16384")
+				w.Header().Set("Content-Length", "4096")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(content[4096:5000])
+				panic(http.ErrAbortHandler)
+			}
+		}
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path, err := DownloadFileWithOptions(context.Background(), srv.URL+"/go.tar.gz", dir, DownloadOptions{
+		HTTPTimeout: time.Minute,
+		Retry:       testRetryPolicy,
+		Connections: 4,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, ranges.Load())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.NoFileExists(t, path+".chunks")
+	assert.NoFileExists(t, path+".state")
+}
+
+func TestDownloadFileParallelResumesLaterCall(t *testing.T) {
+	defer func(size int64) { minChunkSize = size }(minChunkSize)
+	minChunkSize = 1 << 10
+
+	content := testContent(1 << 14)
+	var gets atomic.Int32
+	var failed atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			if strings.HasPrefix(r.Header.Get("Range"), "bytes=4096-") && !failed.Swap(true) {
+				w.Header().Set("Content-Range", "bytes 4096-8191/16384")
+				w.Header().Set("Content-Length", "4096")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(content[4096:5000])
+				panic(http.ErrAbortHandler)
+			}
+		}
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// The transport retries requests that fail on a reused connection, which
+	// would hide the failed chunk.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	opts := DownloadOptions{Client: client, Retry: RetryPolicy{MaxAttempts: 1}, Connections: 4}
+	_, err := DownloadFileWithOptions(context.Background(), srv.URL+"/go.tar.gz", dir, opts)
+	require.Error(t, err)
+	assert.FileExists(t, filepath.Join(dir, "go.tar.gz.chunks"))
+	assert.FileExists(t, filepath.Join(dir, "go.tar.gz.state"))
+
+	// Only the failed chunk is fetched again.
+	gets.Store(0)
+	path, err := DownloadFileWithOptions(context.Background(), srv.URL+"/go.tar.gz", dir, opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, gets.Load())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.NoFileExists(t, path+".state")
+}
+
+func TestDownloadFileResumeChangedFile(t *testing.T) {
+	old := testContent(1 << 16)
+	content := bytes.ToUpper(old)
+	var requests atomic.Int32
+	var ifRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", "65536")
+			_, _ = w.Write(old[:len(old)/2])
+			panic(http.ErrAbortHandler)
+		}
+		// The file changed so the range is ignored.
+		ifRange = r.Header.Get("If-Range")
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "go.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	path, err := DownloadFile(srv.URL+"/go.tar.gz", t.TempDir(), time.Minute, testRetryPolicy)
+	require.NoError(t, err)
+	assert.Equal(t, `"v1"`, ifRange)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func TestDownloadFileNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := DownloadFile(srv.URL+"/go.tar.gz", t.TempDir(), time.Minute, testRetryPolicy)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDownloadFileCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := DownloadFileWithOptions(ctx, srv.URL+"/go.tar.gz", t.TempDir(), DownloadOptions{
+		Retry: RetryPolicy{MaxAttempts: 5, InitialDelay: time.Minute},
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
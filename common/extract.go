@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -16,17 +17,148 @@ import (
 )
 
 func Extract(sourceFile, destinationDir string) error {
+	return ExtractWithProgress(context.Background(), sourceFile, destinationDir, nil)
+}
+
+// ExtractWithProgress extracts a .tar.gz or .zip archive into destinationDir.
+// If progress is not nil it is called with the name of each file after it is
+// written. Extraction stops with ctx's error when ctx is done, leaving the
+// files extracted so far in place.
+func ExtractWithProgress(ctx context.Context, sourceFile, destinationDir string, progress func(name string)) error {
+	written := func(name string) error {
+		if progress != nil {
+			progress(name)
+		}
+		return ctx.Err()
+	}
+
 	switch {
 	case strings.HasSuffix(sourceFile, ".tar.gz"), strings.HasSuffix(sourceFile, ".tgz"):
-		return untarFile(sourceFile, destinationDir)
+		return untarFile(sourceFile, destinationDir, written)
 	case strings.HasSuffix(sourceFile, ".zip"):
-		return unzip(sourceFile, destinationDir)
+		return unzip(sourceFile, destinationDir, written)
 	default:
 		return fmt.Errorf("failed to extract %v, unhandled file type", sourceFile)
 	}
 }
 
-func unzip(sourceFile, destinationDir string) error {
+// maxReadArchiveFileSize is the largest file that ReadArchiveFile returns.
+const maxReadArchiveFileSize = 1 << 20
+
+// ErrArchiveFileNotFound is returned by ReadArchiveFile when the archive does
+// not contain the requested file.
+var ErrArchiveFileNotFound = errors.New("file not found in archive")
+
+// ReadArchiveFile returns the contents of a single file from a .tar.gz or .zip
+// archive without extracting the rest of the archive. name is the slash
+// separated path of the file within the archive.
+func ReadArchiveFile(sourceFile, name string) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(sourceFile, ".tar.gz"), strings.HasSuffix(sourceFile, ".tgz"):
+		return readTarFile(sourceFile, name)
+	case strings.HasSuffix(sourceFile, ".zip"):
+		return readZipFile(sourceFile, name)
+	default:
+		return nil, fmt.Errorf("failed to read %v, unhandled file type", sourceFile)
+	}
+}
+
+// WalkArchive calls fn with the slash separated name of each entry of a
+// .tar.gz or .zip archive, in archive order, until fn returns false.
+func WalkArchive(sourceFile string, fn func(name string) bool) error {
+	switch {
+	case strings.HasSuffix(sourceFile, ".tar.gz"), strings.HasSuffix(sourceFile, ".tgz"):
+		file, err := os.Open(sourceFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("requires gzip-compressed body: %w", err)
+		}
+		tr := tar.NewReader(zr)
+		for {
+			f, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("tar error: %w", err)
+			}
+			if !fn(f.Name) {
+				return nil
+			}
+		}
+	case strings.HasSuffix(sourceFile, ".zip"):
+		r, err := zip.OpenReader(sourceFile)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			if !fn(f.Name) {
+				return nil
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("failed to read %v, unhandled file type", sourceFile)
+	}
+}
+
+func readZipFile(sourceFile, name string) ([]byte, error) {
+	r, err := zip.OpenReader(sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != name || f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxReadArchiveFileSize))
+	}
+	return nil, fmt.Errorf("%v in %v: %w", name, sourceFile, ErrArchiveFileNotFound)
+}
+
+func readTarFile(sourceFile, name string) ([]byte, error) {
+	file, err := os.Open(sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("requires gzip-compressed body: %w", err)
+	}
+
+	tr := tar.NewReader(zr)
+	for {
+		f, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar error: %w", err)
+		}
+		if f.Name == name && f.Typeflag == tar.TypeReg {
+			return io.ReadAll(io.LimitReader(tr, maxReadArchiveFileSize))
+		}
+	}
+	return nil, fmt.Errorf("%v in %v: %w", name, sourceFile, ErrArchiveFileNotFound)
+}
+
+func unzip(sourceFile, destinationDir string, progress func(string) error) error {
 	r, err := zip.OpenReader(sourceFile)
 	if err != nil {
 		return err
@@ -44,14 +176,19 @@ func unzip(sourceFile, destinationDir string) error {
 		}
 		defer rc.Close()
 
-		path := filepath.Join(destinationDir, f.Name)
+		if !validRelPath(f.Name) {
+			return fmt.Errorf("zip contained invalid name %q", f.Name)
+		}
+		path := filepath.Join(destinationDir, filepath.FromSlash(f.Name))
 
 		if f.FileInfo().IsDir() {
-			if err = os.MkdirAll(path, f.Mode()); err != nil {
+			if err = os.MkdirAll(path, f.Mode()|0o700); err != nil {
 				return fmt.Errorf("failed to mkdir %v: %w", path, err)
 			}
 		} else {
-			if err = os.MkdirAll(filepath.Dir(path), f.Mode()); err != nil {
+			// Use the default mode for parent directories that are created
+			// before (or without) their own zip entry.
+			if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 				return fmt.Errorf("failed to mkdir %v: %w", path, err)
 			}
 			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
@@ -73,19 +210,24 @@ func unzip(sourceFile, destinationDir string) error {
 		if err != nil {
 			return fmt.Errorf("failed extracting %q from %q: %w", f.Name, sourceFile, err)
 		}
+		if !f.FileInfo().IsDir() {
+			if err := progress(f.Name); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-func untarFile(sourceFile, destinationDir string) error {
+func untarFile(sourceFile, destinationDir string, progress func(string) error) error {
 	file, err := os.Open(sourceFile)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	return untar(file, destinationDir)
+	return untar(file, destinationDir, progress)
 }
 
 // Copyright 2017 The Go Authors. All rights reserved.
@@ -94,8 +236,10 @@ func untarFile(sourceFile, destinationDir string) error {
 //
 // Modified from golang.org/x/build/internal/untar.
 
-// untar reads the gzip-compressed tar file from r and writes it into dir.
-func untar(r io.Reader, dir string) (err error) {
+// untar reads the gzip-compressed tar file from r and writes it into dir. The
+// name of each file is passed to progress after it is written. Extraction stops
+// if progress returns an error.
+func untar(r io.Reader, dir string, progress func(string) error) (err error) {
 	t0 := time.Now()
 	madeDir := map[string]bool{}
 
@@ -170,6 +314,9 @@ func untar(r io.Reader, dir string) (err error) {
 			if !modTime.IsZero() {
 				_ = os.Chtimes(abs, modTime, modTime)
 			}
+			if err := progress(f.Name); err != nil {
+				return err
+			}
 		case tar.TypeDir:
 			if err := os.MkdirAll(abs, 0o755); err != nil {
 				return err
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractZipRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "evil.zip")
+
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"go/VERSION", "go/../../evil"} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte("data"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	dest := filepath.Join(dir, "a", "b")
+	err = Extract(archive, dest)
+	assert.ErrorContains(t, err, "invalid name")
+	assert.NoFileExists(t, filepath.Join(dir, "evil"))
+}
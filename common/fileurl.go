@@ -0,0 +1,70 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FileURL returns a file:// URL for the given local path.
+func FileURL(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		// Windows paths like C:/go need a leading slash.
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String(), nil
+}
+
+// FileURLPath returns the local path referenced by a file:// URL. It returns
+// false if rawURL is not a file:// URL.
+func FileURLPath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+
+	p := u.Path
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		// Strip the leading slash from /C:/go.
+		p = p[1:]
+	}
+	return filepath.FromSlash(p), true
+}
+
+// copyFile copies the local file src into destinationDir. It returns
+// ErrNotFound if src does not exist.
+func copyFile(src, destinationDir string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	defer in.Close()
+
+	name := filepath.Join(destinationDir, filepath.Base(src))
+	out, err := os.Create(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return "", fmt.Errorf("failed to copy %v: %w", src, err)
+	}
+	if err = out.Close(); err != nil {
+		return "", err
+	}
+	return name, nil
+}
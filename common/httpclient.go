@@ -0,0 +1,256 @@
+package common
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// HTTPClientConfig configures the HTTP client created by NewHTTPClient.
+type HTTPClientConfig struct {
+	// Timeout is the timeout of each HTTP request.
+	Timeout time.Duration
+
+	// Proxy is the URL of the HTTP proxy to use. If empty the proxy is taken
+	// from the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables.
+	Proxy string
+
+	// CAFile is a PEM file of certificate authorities that are trusted in
+	// addition to the system roots.
+	CAFile string
+
+	// CertFile and KeyFile are a PEM encoded client certificate and key used
+	// for TLS client authentication.
+	CertFile string
+	KeyFile  string
+
+	// BearerToken is sent as an "Authorization: Bearer" header. It takes
+	// precedence over Username and Password.
+	BearerToken string
+
+	// Username and Password are sent using HTTP basic authentication.
+	Username string
+	Password string
+
+	// AuthHosts limits BearerToken, Username, and Password to requests to
+	// these hosts (host or host:port) so that credentials are not leaked to
+	// other servers, e.g. after a redirect. Credentials are not sent if
+	// AuthHosts is empty.
+	AuthHosts []string
+
+	// Netrc enables looking up credentials for hosts without explicit
+	// credentials in the file named by $NETRC or ~/.netrc (_netrc on
+	// Windows).
+	Netrc bool
+
+	// UserAgent is sent in the User-Agent header if not empty.
+	UserAgent string
+}
+
+// NewHTTPClient returns an HTTP client configured by cfg.
+func NewHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.CAFile != "" || cfg.CertFile != "" || cfg.KeyFile != "" {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	rt := &headerTransport{
+		next:      transport,
+		userAgent: cfg.UserAgent,
+		hosts:     map[string]bool{},
+	}
+	switch {
+	case cfg.BearerToken != "":
+		rt.auth = "Bearer " + cfg.BearerToken
+	case cfg.Username != "" || cfg.Password != "":
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+		rt.auth = req.Header.Get("Authorization")
+	}
+	for _, h := range cfg.AuthHosts {
+		rt.hosts[strings.ToLower(h)] = true
+	}
+	if cfg.Netrc {
+		machines, err := readNetrc(netrcPath())
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		rt.netrc = machines
+	}
+
+	return &http.Client{Timeout: cfg.Timeout, Transport: rt}, nil
+}
+
+func newTLSConfig(cfg HTTPClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %v", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("both a client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// headerTransport adds the User-Agent and credentials to requests.
+type headerTransport struct {
+	next      http.RoundTripper
+	userAgent string
+	auth      string          // Authorization header value for hosts.
+	hosts     map[string]bool // Hosts that auth is sent to.
+	netrc     []netrcMachine
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auth := t.authorization(req.URL)
+	if (t.userAgent == "" || req.Header.Get("User-Agent") != "") && (auth == "" || req.Header.Get("Authorization") != "") {
+		return t.next.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request.
+	req = req.Clone(req.Context())
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	if auth != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// authorization returns the Authorization header for a request to u, or an
+// empty string if no credentials are configured for its host.
+func (t *headerTransport) authorization(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if t.auth != "" && (t.hosts[host] || t.hosts[strings.ToLower(u.Hostname())]) {
+		return t.auth
+	}
+	for _, m := range t.netrc {
+		if strings.EqualFold(m.name, u.Hostname()) {
+			req := &http.Request{Header: http.Header{}}
+			req.SetBasicAuth(m.login, m.password)
+			return req.Header.Get("Authorization")
+		}
+	}
+	return ""
+}
+
+// netrcMachine is a machine entry of a .netrc file.
+type netrcMachine struct {
+	name     string
+	login    string
+	password string
+}
+
+// netrcPath returns the path of the user's .netrc file.
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(home, name)
+}
+
+// readNetrc reads the machine entries of a .netrc file. Entries without a
+// login and password and the default entry are ignored.
+func readNetrc(path string) ([]netrcMachine, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var machines []netrcMachine
+	var cur *netrcMachine
+	flush := func() {
+		if cur != nil && cur.login != "" && cur.password != "" {
+			machines = append(machines, *cur)
+		}
+		cur = nil
+	}
+
+	s := bufio.NewScanner(f)
+	s.Split(bufio.ScanWords)
+	for s.Scan() {
+		switch tok := s.Text(); tok {
+		case "machine":
+			flush()
+			if s.Scan() {
+				cur = &netrcMachine{name: s.Text()}
+			}
+		case "default":
+			flush()
+		case "login", "password", "account":
+			if !s.Scan() {
+				break
+			}
+			if cur == nil {
+				continue
+			}
+			if tok == "login" {
+				cur.login = s.Text()
+			} else if tok == "password" {
+				cur.password = s.Text()
+			}
+		case "macdef":
+			// Macro definitions run until a blank line, which ScanWords cannot
+			// see. They are rare in practice so stop parsing.
+			flush()
+			return machines, s.Err()
+		}
+	}
+	flush()
+	return machines, s.Err()
+}
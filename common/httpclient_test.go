@@ -0,0 +1,94 @@
+package common
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClientCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// The test server's certificate is not trusted by default.
+	c, err := NewHTTPClient(HTTPClientConfig{})
+	require.NoError(t, err)
+	_, err = c.Get(srv.URL)
+	require.Error(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, pemData, 0o644))
+
+	c, err = NewHTTPClient(HTTPClientConfig{CAFile: caFile})
+	require.NoError(t, err)
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = NewHTTPClient(HTTPClientConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+}
+
+func TestNewHTTPClientAuth(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	get := func(cfg HTTPClientConfig) http.Header {
+		t.Helper()
+		c, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		resp, err := c.Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return <-headers
+	}
+
+	h := get(HTTPClientConfig{BearerToken: "secret", AuthHosts: []string{u.Host}, UserAgent: "gvm/test"})
+	assert.Equal(t, "Bearer secret", h.Get("Authorization"))
+	assert.Equal(t, "gvm/test", h.Get("User-Agent"))
+
+	h = get(HTTPClientConfig{Username: "user", Password: "pass", AuthHosts: []string{u.Hostname()}})
+	assert.Equal(t, "Basic dXNlcjpwYXNz", h.Get("Authorization"))
+
+	// Credentials are not sent to other hosts.
+	h = get(HTTPClientConfig{BearerToken: "secret", AuthHosts: []string{"example.com"}})
+	assert.Empty(t, h.Get("Authorization"))
+
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(netrc, []byte("machine example.com login a password b\n"+
+		"machine "+u.Hostname()+"\n  login user\n  password pass\n"), 0o600))
+	t.Setenv("NETRC", netrc)
+	h = get(HTTPClientConfig{Netrc: true})
+	assert.Equal(t, "Basic dXNlcjpwYXNz", h.Get("Authorization"))
+}
+
+func TestReadNetrc(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(path, []byte(`
+machine a.example.com login alice password p1
+machine b.example.com login bob
+default login anon password none
+machine c.example.com account x password p3 login carol
+`), 0o600))
+
+	machines, err := readNetrc(path)
+	require.NoError(t, err)
+	assert.Equal(t, []netrcMachine{
+		{name: "a.example.com", login: "alice", password: "p1"},
+		{name: "c.example.com", login: "carol", password: "p3"},
+	}, machines)
+}
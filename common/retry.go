@@ -0,0 +1,191 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed HTTP requests are retried. The delay before
+// retry n is InitialDelay * Multiplier^(n-1), capped at MaxDelay and randomized
+// by Jitter. A Retry-After header sent with a 429 or 503 response overrides
+// the computed delay, but is still capped at MaxDelay, or at MaxElapsed if
+// there is no MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// Values <= 1 disable retries.
+	MaxAttempts int
+
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+
+	// MaxDelay caps the delay between attempts. Zero means no cap.
+	MaxDelay time.Duration
+
+	// Multiplier is the factor the delay grows by after each retry. Values
+	// < 1 are treated as 1 (a constant delay).
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction of the delay
+	// (e.g. 0.2 is +/- 20%). It is clamped to [0, 1].
+	Jitter float64
+
+	// MaxElapsed stops retrying once the next attempt would start more than
+	// MaxElapsed after the first. Zero means no limit.
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy makes up to 5 attempts with delays of about 1s, 2s, 4s,
+// and 8s, giving up after 2 minutes.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  5,
+	InitialDelay: time.Second,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	MaxElapsed:   2 * time.Minute,
+}
+
+// DefaultRetryParams is the retry policy used by DownloadFile.
+//
+// Deprecated: Use DefaultRetryPolicy.
+var DefaultRetryParams = DefaultRetryPolicy
+
+// Do calls fn until it succeeds, returns an error that is not retryable, or
+// the policy is exhausted. It returns the last error from fn, or ctx's error
+// if ctx is done while waiting to retry.
+func (p RetryPolicy) Do(ctx context.Context, fn func() (retryable bool, err error)) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		retryable, err := fn()
+		if err == nil || !retryable || ctx.Err() != nil || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		delay := p.Delay(attempt, err)
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			log.WithError(err).Debugf("Attempt %d failed, not retrying after %v", attempt, p.MaxElapsed)
+			return err
+		}
+
+		log.WithError(err).Debugf("Attempt %d/%d failed, retrying in %s", attempt, p.MaxAttempts, delay)
+		if err := SleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Delay returns the time to wait after the given attempt failed with err.
+func (p RetryPolicy) Delay(attempt int, err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		switch {
+		case p.MaxDelay > 0:
+			return min(statusErr.RetryAfter, p.MaxDelay)
+		case p.MaxElapsed > 0:
+			return min(statusErr.RetryAfter, p.MaxElapsed)
+		}
+		return statusErr.RetryAfter
+	}
+
+	multiplier := math.Max(p.Multiplier, 1)
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+	switch {
+	case delay <= 0 || math.IsNaN(delay):
+		return 0
+	case delay >= math.MaxInt64:
+		return math.MaxInt64
+	}
+	return time.Duration(delay)
+}
+
+// StatusError is returned when a server responds with an unexpected HTTP
+// status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+
+	// RetryAfter is the delay requested by the Retry-After header of a 429 or
+	// 503 response, or zero.
+	RetryAfter time.Duration
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	err := &StatusError{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return err
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request %v failed with http status %v", e.URL, e.StatusCode)
+}
+
+// Retryable returns true if the request may succeed when retried. Client
+// errors other than 408 and 429 are permanent.
+func (e *StatusError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout, e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return false
+	default:
+		return true
+	}
+}
+
+// CheckResponse returns nil if the response has a 200 status. It returns
+// ErrNotFound for a 404 and a *StatusError otherwise. retryable reports
+// whether the request should be retried.
+func CheckResponse(resp *http.Response) (retryable bool, err error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return false, nil
+	case http.StatusNotFound:
+		return false, ErrNotFound
+	}
+	statusErr := newStatusError(resp)
+	return statusErr.Retryable(), statusErr
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. It returns zero if the header is missing or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// SleepContext waits for d or until ctx is done.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
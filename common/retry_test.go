@@ -0,0 +1,123 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, p.Delay(1, errors.New("fail")))
+	assert.Equal(t, 2*time.Second, p.Delay(2, errors.New("fail")))
+	assert.Equal(t, 4*time.Second, p.Delay(3, errors.New("fail")))
+	assert.Equal(t, 5*time.Second, p.Delay(4, errors.New("fail")))
+
+	// Retry-After takes precedence over the backoff, up to MaxDelay or else
+	// MaxElapsed.
+	retryAfter := &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+	assert.Equal(t, 3*time.Second, p.Delay(1, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}))
+	assert.Equal(t, 5*time.Second, p.Delay(1, retryAfter))
+	assert.Equal(t, 10*time.Second, RetryPolicy{MaxElapsed: 10 * time.Second}.Delay(1, retryAfter))
+	assert.Equal(t, time.Minute, RetryPolicy{}.Delay(1, retryAfter))
+
+	// Jitter is clamped to [0, 1] and delays are never negative.
+	for _, jitter := range []float64{-1, 5} {
+		p := RetryPolicy{InitialDelay: time.Second, Jitter: jitter}
+		for i := 0; i < 100; i++ {
+			d := p.Delay(1, errors.New("fail"))
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, 2*time.Second)
+		}
+	}
+	assert.Equal(t, time.Duration(math.MaxInt64), RetryPolicy{InitialDelay: time.Hour, Multiplier: 10}.Delay(100, errors.New("fail")))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Delay(2, errors.New("fail"))
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.LessOrEqual(t, d, 3*time.Second)
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+
+	var attempts int
+	err := p.Do(context.Background(), func() (bool, error) {
+		attempts++
+		return true, errors.New("fail")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = p.Do(context.Background(), func() (bool, error) {
+		attempts++
+		return false, errors.New("permanent")
+	})
+	assert.EqualError(t, err, "permanent")
+	assert.Equal(t, 1, attempts)
+
+	// Attempts that would start after MaxElapsed are not made.
+	p = RetryPolicy{MaxAttempts: 5, InitialDelay: time.Minute, MaxElapsed: time.Second}
+	attempts = 0
+	err = p.Do(context.Background(), func() (bool, error) {
+		attempts++
+		return true, errors.New("fail")
+	})
+	assert.EqualError(t, err, "fail")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 7, 2, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Tue, 02 Jul 2024 12:01:30 GMT", now))
+	assert.Zero(t, parseRetryAfter("Tue, 02 Jul 2024 11:00:00 GMT", now))
+	assert.Zero(t, parseRetryAfter("-1", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter("", now))
+}
+
+func TestDownloadFileRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(testContent(1 << 10))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := DownloadFile(srv.URL+"/go.tar.gz", t.TempDir(), time.Minute, testRetryPolicy)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, requests.Load())
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestDownloadFileClientError(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	_, err := DownloadFile(srv.URL+"/go.tar.gz", t.TempDir(), time.Minute, testRetryPolicy)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	assert.EqualValues(t, 1, requests.Load())
+}
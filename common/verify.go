@@ -0,0 +1,69 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNoChecksum is returned when a file cannot be verified because no expected
+// checksum is known for it.
+var ErrNoChecksum = errors.New("no checksum available")
+
+// ChecksumError is returned when a file does not match its expected size or
+// SHA-256 hash.
+type ChecksumError struct {
+	File           string // Path to the file that failed verification.
+	ExpectedSHA256 string
+	ActualSHA256   string
+	ExpectedSize   int64
+	ActualSize     int64
+}
+
+func (e *ChecksumError) Error() string {
+	if e.ExpectedSize > 0 && e.ExpectedSize != e.ActualSize {
+		return fmt.Sprintf("size mismatch for %v: expected %d bytes, got %d bytes",
+			e.File, e.ExpectedSize, e.ActualSize)
+	}
+	return fmt.Sprintf("sha256 mismatch for %v: expected %v, got %v",
+		e.File, e.ExpectedSHA256, e.ActualSHA256)
+}
+
+// VerifyFile checks that the file at path has the given size and SHA-256 hash.
+// A size <= 0 disables the size check. It returns a *ChecksumError if the file
+// does not match.
+func VerifyFile(path string, size int64, sha256Hex string) error {
+	if sha256Hex == "" {
+		return fmt.Errorf("cannot verify %v: %w", path, ErrNoChecksum)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("failed to hash %v: %w", path, err)
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if (size > 0 && n != size) || !strings.EqualFold(actual, sha256Hex) {
+		return &ChecksumError{
+			File:           path,
+			ExpectedSHA256: sha256Hex,
+			ActualSHA256:   actual,
+			ExpectedSize:   size,
+			ActualSize:     n,
+		}
+	}
+
+	log.WithField("file", path).Debug("Verified file checksum")
+	return nil
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+
+	const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	t.Run("match", func(t *testing.T) {
+		assert.NoError(t, VerifyFile(path, 5, helloSHA256))
+	})
+
+	t.Run("no_size", func(t *testing.T) {
+		assert.NoError(t, VerifyFile(path, 0, helloSHA256))
+	})
+
+	t.Run("size_mismatch", func(t *testing.T) {
+		err := VerifyFile(path, 6, helloSHA256)
+		var checksumErr *ChecksumError
+		require.True(t, errors.As(err, &checksumErr), "expected ChecksumError, got %v", err)
+		assert.EqualValues(t, 5, checksumErr.ActualSize)
+	})
+
+	t.Run("hash_mismatch", func(t *testing.T) {
+		err := VerifyFile(path, 5, "00")
+		var checksumErr *ChecksumError
+		require.True(t, errors.As(err, &checksumErr), "expected ChecksumError, got %v", err)
+		assert.Equal(t, helloSHA256, checksumErr.ActualSHA256)
+	})
+
+	t.Run("no_checksum", func(t *testing.T) {
+		assert.ErrorIs(t, VerifyFile(path, 5, ""), ErrNoChecksum)
+	})
+}
@@ -0,0 +1,120 @@
+package gvm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// constraintTermRegexp matches a single constraint term like ">=1.21".
+var constraintTermRegexp = regexp.MustCompile(`^\s*(>=|<=|!=|>|<|=|~)?\s*(?:go)?(\S+)\s*$`)
+
+// Constraints is a set of version requirements, such as ">=1.21, <1.23" or
+// "~1.22", that must all be satisfied by a version.
+//
+// Constraints follow Go release naming. Versions like 1.20 and 1.20.0 are
+// equivalent. A pre-release such as 1.21rc2 sorts before 1.21.0 and only
+// satisfies the constraints if at least one term names a pre-release (e.g.
+// ">=1.21rc1"). The tilde operator allows patch releases of the given minor
+// version, so "~1.22" is equivalent to ">=1.22.0, <1.23.0" and "~1.22.3" is
+// equivalent to ">=1.22.3, <1.23.0".
+type Constraints struct {
+	in         string
+	terms      []constraintTerm
+	prerelease bool // A term names a pre-release so pre-releases may match.
+}
+
+type constraintTerm struct {
+	op      string
+	version *GoVersion
+}
+
+// ParseConstraints parses a comma-separated list of version constraints.
+func ParseConstraints(in string) (*Constraints, error) {
+	c := &Constraints{in: in}
+
+	for _, term := range strings.Split(in, ",") {
+		m := constraintTermRegexp.FindStringSubmatch(term)
+		if m == nil {
+			return nil, fmt.Errorf("invalid version constraint %q", term)
+		}
+		op, ver := m[1], m[2]
+
+		v, err := ParseVersion(ver)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in constraint %q: %w", term, err)
+		}
+		if v.IsTip() {
+			return nil, fmt.Errorf("invalid version constraint %q: tip cannot be compared", term)
+		}
+		c.prerelease = c.prerelease || v.Prerelease()
+
+		switch op {
+		case "~":
+			upper, err := nextMinor(v)
+			if err != nil {
+				return nil, err
+			}
+			c.terms = append(c.terms,
+				constraintTerm{op: ">=", version: v},
+				constraintTerm{op: "<", version: upper})
+		case "":
+			c.terms = append(c.terms, constraintTerm{op: "=", version: v})
+		default:
+			c.terms = append(c.terms, constraintTerm{op: op, version: v})
+		}
+	}
+
+	return c, nil
+}
+
+// Check returns true if the version satisfies all constraints.
+func (c *Constraints) Check(v *GoVersion) bool {
+	if !v.isRelease() || (v.Prerelease() && !c.prerelease) {
+		return false
+	}
+
+	for _, t := range c.terms {
+		cmp := v.version.Compare(t.version.version)
+
+		var ok bool
+		switch t.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Constraints) String() string {
+	return c.in
+}
+
+// isConstraintSpec returns true if the version specifier is a constraint
+// rather than a version or symbolic name.
+func isConstraintSpec(spec string) bool {
+	return strings.ContainsAny(spec, "<>=!~,")
+}
+
+// nextMinor returns the first release of the minor version that follows v. For
+// a version with only a major number the next major version is returned.
+func nextMinor(v *GoVersion) (*GoVersion, error) {
+	seg := v.version.Segments()
+	if strings.Count(strings.TrimPrefix(v.in, "go"), ".") == 0 {
+		return ParseVersion(fmt.Sprintf("%d.0.0", seg[0]+1))
+	}
+	return ParseVersion(fmt.Sprintf("%d.%d.0", seg[0], seg[1]+1))
+}
@@ -0,0 +1,73 @@
+package gvm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraints(t *testing.T) {
+	cases := []struct {
+		Constraint string
+		Match      []string
+		NoMatch    []string
+	}{
+		{
+			Constraint: ">=1.21, <1.23",
+			Match:      []string{"1.21.0", "1.21", "1.22.5"},
+			NoMatch:    []string{"1.20.14", "1.21rc2", "1.23.0", "1.23rc1", "tip"},
+		},
+		{
+			Constraint: "~1.22",
+			Match:      []string{"1.22.0", "1.22.9"},
+			NoMatch:    []string{"1.21.13", "1.23.0", "1.22rc1"},
+		},
+		{
+			Constraint: "~1.22.3",
+			Match:      []string{"1.22.3", "1.22.9"},
+			NoMatch:    []string{"1.22.2", "1.23.0"},
+		},
+		{
+			Constraint: "~1",
+			Match:      []string{"1.4", "1.22.9"},
+			NoMatch:    []string{"2.0.0"},
+		},
+		{
+			Constraint: ">=1.21rc1",
+			Match:      []string{"1.21rc1", "1.21rc2", "1.21.0", "1.23rc1"},
+			NoMatch:    []string{"1.21beta1", "1.20.14"},
+		},
+		{
+			Constraint: "1.20",
+			Match:      []string{"1.20", "1.20.0"},
+			NoMatch:    []string{"1.20.1"},
+		},
+		{
+			Constraint: ">= go1.20, != 1.20.3",
+			Match:      []string{"1.20.2", "1.20.4"},
+			NoMatch:    []string{"1.20.3"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Constraint, func(t *testing.T) {
+			c, err := ParseConstraints(tc.Constraint)
+			require.NoError(t, err)
+
+			for _, v := range tc.Match {
+				assert.True(t, c.Check(MustParseVersion(v)), "expected %v to match", v)
+			}
+			for _, v := range tc.NoMatch {
+				assert.False(t, c.Check(MustParseVersion(v)), "expected %v not to match", v)
+			}
+		})
+	}
+}
+
+func TestParseConstraintsInvalid(t *testing.T) {
+	for _, in := range []string{">=", ">=1.21,", "=>1.21", ">=tip"} {
+		_, err := ParseConstraints(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,46 @@
+package gvm
+
+import (
+	"context"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxFlightRetries limits how often a caller starts a new flight because the
+// callers that started the previous ones were canceled.
+const maxFlightRetries = 3
+
+// flightResult is the value of a flight.
+type flightResult[T any] struct {
+	val T
+
+	// canceled is true if the context of the caller that started the flight
+	// was done when fn returned.
+	canceled bool
+}
+
+// shareFlight runs fn once for concurrent callers that use the same key and
+// returns its result to all of them. fn runs with the context of the caller
+// that started it. If that caller is canceled the callers that are still
+// waiting start a new flight instead of failing with its error, up to
+// maxFlightRetries times.
+func shareFlight[T any](ctx context.Context, g *singleflight.Group, key string, fn func(context.Context) (T, error)) (T, error) {
+	for retries := 0; ; retries++ {
+		ch := g.DoChan(key, func() (interface{}, error) {
+			v, err := fn(ctx)
+			return flightResult[T]{val: v, canceled: ctx.Err() != nil}, err
+		})
+
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case r := <-ch:
+			res, _ := r.Val.(flightResult[T])
+			if r.Err != nil && res.canceled && ctx.Err() == nil && retries < maxFlightRetries {
+				continue
+			}
+			return res.val, r.Err
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gvm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/singleflight"
+)
+
+func TestInstallConcurrent(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.21.0", "1.22.5")
+	files := http.FileServer(http.Dir(mirror))
+	var indexRequests, archiveRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			indexRequests.Add(1)
+			http.ServeFile(w, r, filepath.Join(mirror, localIndexFile))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".tar.gz") {
+			archiveRequests.Add(1)
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL)
+	versions := []string{"1.21.0", "1.22.5"}
+
+	var wg sync.WaitGroup
+	dirs := make([]string, 20)
+	errs := make([]error, len(dirs))
+	for i := range dirs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dirs[i], errs[i] = m.Install(MustParseVersion(versions[i%len(versions)]))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, dir := range dirs {
+		require.NoError(t, errs[i])
+		assert.Equal(t, m.VersionGoROOT(MustParseVersion(versions[i%len(versions)])), dir)
+	}
+	assert.EqualValues(t, 1, indexRequests.Load())
+	assert.EqualValues(t, len(versions), archiveRequests.Load())
+
+	installed, err := m.Installed()
+	require.NoError(t, err)
+	assert.Len(t, installed, len(versions))
+}
+
+func TestShareFlightCanceled(t *testing.T) {
+	var g singleflight.Group
+	started := make(chan struct{})
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return "done", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := shareFlight(ctx, &g, "key", fn)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		v, err := shareFlight(context.Background(), &g, "key", fn)
+		assert.NoError(t, err)
+		second <- v
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	// The second caller was not canceled so it runs fn again.
+	assert.Equal(t, "done", <-second)
+}
+
+func TestShareFlightTimeoutNotRetried(t *testing.T) {
+	var g singleflight.Group
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		// Like an http.Client.Timeout error, which wraps DeadlineExceeded
+		// although the caller's context is live.
+		return "", fmt.Errorf("request failed: %w", context.DeadlineExceeded)
+	}
+
+	_, err := shareFlight(context.Background(), &g, "key", fn)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualValues(t, 1, calls.Load())
+}
@@ -4,20 +4,28 @@
 package gvm
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/andrewkroh/gvm/common"
 )
 
+// ErrOffline is returned when an operation requires network access while the
+// Manager is in offline mode.
+var ErrOffline = errors.New("network access disabled in offline mode")
+
 type AvailableVersion struct {
 	Version *GoVersion
 	Source  bool // Available to install from source.
@@ -37,6 +45,10 @@ func (av AvailableVersion) String() string {
 	}
 }
 
+// Manager installs and manages Go versions under its Home directory. Set the
+// exported fields and call Init before use. After Init a Manager is safe for
+// concurrent use by multiple goroutines, and concurrent requests to install the
+// same version share a single download or build.
 type Manager struct {
 	// GVM Home directory. Defaults to $HOME/.gvm
 	Home string
@@ -49,20 +61,107 @@ type Manager struct {
 
 	// GoStorageHome is the base URL for the Go downloads API.
 	// Defaults to https://go.dev/dl
+	//
+	// It may also be a file:// URL or a path to a local directory. The
+	// directory must contain an index.json file with the same contents as the
+	// API's ?mode=json&include=all response along with the archives it lists.
+	//
+	// Multiple mirrors may be given as a comma-separated list. They are tried
+	// in order, failing over to the next mirror on connection errors and 5xx
+	// responses. Mirrors that fail are tried last for the rest of the process.
+	// A comma within a mirror must be escaped as %2C, so a directory whose
+	// path contains a comma must be given as a file:// URL.
 	GoStorageHome string
 
+	// BootstrapGOROOT is the GOROOT of the Go toolchain used to build Go from
+	// source. Defaults to $GOROOT_BOOTSTRAP. If both are empty an installed
+	// version that meets the requirement of the version being built is used,
+	// or the required version is installed.
+	BootstrapGOROOT string
+
 	// GoSourceURL configres the update git repository to download and update local
 	// source checkouts from.
 	// Defaults to https://go.googlesource.com/go
 	GoSourceURL string
 
+	// SourceFilter is the --filter of the partial clone used as the source
+	// cache. Defaults to blob:none, which downloads file contents only when a
+	// version is checked out for a build. Set it to "none" for a full clone,
+	// which can build any version while Offline.
+	SourceFilter string
+
+	// HTTPTimeout is the timeout of each HTTP request made with the default
+	// HTTP client. Defaults to 3 minutes.
 	HTTPTimeout time.Duration
 
+	// HTTPClient is used for all HTTP requests. Set it to use a custom
+	// Transport for proxies, TLS settings, or authentication (see
+	// common.NewHTTPClient). If nil a client with HTTPTimeout is used.
+	HTTPClient *http.Client
+
+	// DownloadConnections is the number of concurrent range requests used to
+	// download an archive when the server supports them. Values <= 1 download
+	// archives as a single stream.
+	DownloadConnections int
+
+	// LockTimeout is how long to wait for another process that is installing
+	// the same version or updating the same cache under Home. A negative value waits
+	// forever. Defaults to 30 minutes.
+	LockTimeout time.Duration
+
+	// Retry controls how failed requests for the release index and archive
+	// downloads are retried. Defaults to common.DefaultRetryPolicy if
+	// MaxAttempts is 0.
+	Retry common.RetryPolicy
+
+	// ReleaseIndexTTL is how long the cached release index is used before it
+	// is revalidated with the server. Defaults to 1 hour.
+	ReleaseIndexTTL time.Duration
+
+	// UseModuleProxy installs binary releases from the golang.org/toolchain
+	// module on a Go module proxy instead of from GoStorageHome. Downloads are
+	// verified against the checksum database configured by GOSUMDB. As in the
+	// go command, verification can only be turned off, with GOSUMDB=off or by
+	// GONOSUMDB (or GOPRIVATE) matching golang.org/toolchain, when GOPROXY is
+	// a single file:// URL.
+	UseModuleProxy bool
+
+	// GOPROXY is the list of module proxies used when UseModuleProxy is set.
+	// Defaults to $GOPROXY or https://proxy.golang.org.
+	GOPROXY string
+
+	// Providers are the sources that Go versions are installed from, in order
+	// of preference. Install moves on to the next provider when a provider does
+	// not have the version and Build only uses providers that build from
+	// source. Defaults to a BinaryProvider (or a ModuleProxyProvider if
+	// UseModuleProxy is set) followed by a SourceProvider.
+	Providers []Provider
+
+	// Progress, if not nil, is called to report the progress of downloads,
+	// archive extraction, and builds from source. It may be called
+	// concurrently when versions are installed concurrently.
+	Progress func(ProgressEvent)
+
+	// Offline disables all network access. Only installed versions, cached
+	// archives, the cached release index, and the existing source cache are
+	// used.
+	Offline bool
+
 	Logger logrus.FieldLogger
 
 	cacheDir    string
+	archivesDir string
 	versionsDir string
 	logsDir     string
+	locksDir    string
+
+	releasesMu     sync.Mutex
+	releases       []GoRelease // Release index loaded by fetchGoReleases.
+	releasesLoaded time.Time   // Time the release index was loaded.
+
+	flights singleflight.Group // Deduplicates concurrent installs and index fetches.
+
+	mirrors mirrorHealth // Health of the GoStorageHome mirrors.
 }
 
 func (m *Manager) Init() error {
@@ -77,16 +176,38 @@ func (m *Manager) Init() error {
 
 	if m.GoStorageHome == "" {
 		m.GoStorageHome = "https://go.dev/dl"
+	} else {
+		s, err := normalizeStorageHome(m.GoStorageHome)
+		if err != nil {
+			return err
+		}
+		m.GoStorageHome = s
 	}
 
 	if m.GoSourceURL == "" {
 		m.GoSourceURL = "https://go.googlesource.com/go"
 	}
 
+	if m.SourceFilter == "" {
+		m.SourceFilter = "blob:none"
+	}
+
 	if m.HTTPTimeout == 0 {
 		m.HTTPTimeout = 3 * time.Minute
 	}
 
+	if m.LockTimeout == 0 {
+		m.LockTimeout = 30 * time.Minute
+	}
+
+	if m.Retry.MaxAttempts == 0 {
+		m.Retry = common.DefaultRetryPolicy
+	}
+
+	if m.ReleaseIndexTTL == 0 {
+		m.ReleaseIndexTTL = time.Hour
+	}
+
 	if m.GOOS == "" {
 		m.GOOS = runtime.GOOS
 	}
@@ -105,17 +226,25 @@ func (m *Manager) Init() error {
 	}
 
 	m.cacheDir = filepath.Join(m.Home, "cache")
+	m.archivesDir = filepath.Join(m.cacheDir, "archives")
 	m.versionsDir = filepath.Join(m.Home, "versions")
 	m.logsDir = filepath.Join(m.Home, "logs")
+	m.locksDir = filepath.Join(m.Home, "locks")
 	return m.ensureDirStruct()
 }
 
 func (m *Manager) UpdateCache() error {
-	return m.updateSrcCache()
+	return m.UpdateCacheContext(context.Background())
+}
+
+// UpdateCacheContext is like UpdateCache but stops the git commands when ctx
+// is done.
+func (m *Manager) UpdateCacheContext(ctx context.Context) error {
+	return m.updateSrcCache(ctx)
 }
 
 func (m *Manager) ensureDirStruct() error {
-	for _, dir := range []string{m.cacheDir, m.versionsDir, m.logsDir} {
+	for _, dir := range []string{m.cacheDir, m.archivesDir, m.versionsDir, m.logsDir, m.locksDir} {
 		if err := os.MkdirAll(dir, os.ModeDir|0o755); err != nil {
 			return err
 		}
@@ -123,61 +252,72 @@ func (m *Manager) ensureDirStruct() error {
 	return nil
 }
 
+// Available returns the versions that can be installed from the providers.
+// Providers that fail to list their versions are skipped unless none succeed.
 func (m *Manager) Available() ([]AvailableVersion, error) {
-	if !m.hasSrcCache() {
-		versions, err := m.AvailableBinaries()
+	return m.AvailableContext(context.Background())
+}
+
+// AvailableContext is like Available but stops when ctx is done.
+func (m *Manager) AvailableContext(ctx context.Context) ([]AvailableVersion, error) {
+	versionSet := map[string]*AvailableVersion{}
+	var firstErr error
+	listed := false
+	for _, p := range m.providers() {
+		versions, err := p.List(ctx, m)
 		if err != nil {
-			return nil, err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			m.Logger.WithError(err).WithField("provider", p.Name()).Info("Failed to list available versions.")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
 		}
+		listed = true
 
-		available := make([]AvailableVersion, 0, len(versions))
 		for _, ver := range versions {
-			available = append(available, AvailableVersion{Version: ver, Binary: true})
+			avail, found := versionSet[ver.String()]
+			if !found {
+				avail = &AvailableVersion{Version: ver}
+				versionSet[ver.String()] = avail
+			}
+			if p.Source() {
+				avail.Source = true
+			} else {
+				avail.Binary = true
+			}
 		}
-		return available, nil
 	}
-
-	src, err := m.AvailableSource()
-	if err != nil {
-		return nil, err
+	if !listed && firstErr != nil {
+		return nil, firstErr
 	}
 
-	versionSet := make(map[string]*AvailableVersion, len(src))
-	for _, ver := range src {
-		versionSet[ver.String()] = &AvailableVersion{Version: ver, Source: true}
+	available := make([]AvailableVersion, 0, len(versionSet))
+	for _, ver := range versionSet {
+		available = append(available, *ver)
 	}
+	sort.Slice(available, func(i, j int) bool {
+		return available[i].Version.LessThan(available[j].Version)
+	})
+	return available, nil
+}
 
-	toSlice := func() []AvailableVersion {
-		available := make([]AvailableVersion, 0, len(versionSet))
-		for _, ver := range versionSet {
-			available = append(available, *ver)
-		}
-		sort.Slice(available, func(i, j int) bool {
-			return available[i].Version.LessThan(available[j].Version)
-		})
-		return available
-	}
+// Remove removes the installed version and its cached archives.
+func (m *Manager) Remove(version *GoVersion) error {
+	return m.RemoveContext(context.Background(), version)
+}
 
-	bin, err := m.AvailableBinaries()
+// RemoveContext is like Remove but stops waiting for the version's lock when
+// ctx is done.
+func (m *Manager) RemoveContext(ctx context.Context, version *GoVersion) error {
+	l, err := m.lock(ctx, m.versionLock(version))
 	if err != nil {
-		// Return the source versions if we cannot get binary info.
-		m.Logger.WithError(err).Info("Failed to list available binary versions.")
-		return toSlice(), nil
+		return err
 	}
+	defer l.unlock()
 
-	// Merge source and binary versions.
-	for _, ver := range bin {
-		if avail, found := versionSet[ver.String()]; found {
-			avail.Binary = true
-			continue
-		}
-		versionSet[ver.String()] = &AvailableVersion{Version: ver, Binary: true}
-	}
-
-	return toSlice(), nil
-}
-
-func (m *Manager) Remove(version *GoVersion) error {
 	dir := m.VersionGoROOT(version)
 
 	fi, err := os.Stat(dir)
@@ -189,11 +329,23 @@ func (m *Manager) Remove(version *GoVersion) error {
 		return fmt.Errorf("path %q is not a directory", dir)
 	}
 
-	return os.RemoveAll(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return m.removeCachedArchives(version)
 }
 
-// Installed returns all installed go version
+// Installed returns all installed go versions, including named versions.
 func (m *Manager) Installed() ([]*GoVersion, error) {
+	return m.InstalledContext(context.Background())
+}
+
+// InstalledContext is like Installed but returns ctx.Err() if ctx is done.
+func (m *Manager) InstalledContext(ctx context.Context) ([]*GoVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	files, err := os.ReadDir(m.versionsDir)
 	if err != nil {
 		return nil, err
@@ -203,13 +355,17 @@ func (m *Manager) Installed() ([]*GoVersion, error) {
 
 	list := make([]*GoVersion, 0, len(files))
 	for _, fi := range files {
-		name := fi.Name()
-		name = strings.TrimSuffix(name, versionSuffix)
+		name, ok := strings.CutSuffix(fi.Name(), versionSuffix)
+		if !ok {
+			continue
+		}
 		name = strings.TrimPrefix(name, "go")
 
 		v, err := ParseVersion(name)
 		if err != nil {
-			continue
+			if v, err = NamedVersion(name); err != nil {
+				continue
+			}
 		}
 		list = append(list, v)
 	}
@@ -220,6 +376,14 @@ func (m *Manager) Installed() ([]*GoVersion, error) {
 
 // HasVersion checks if a given go version is installed
 func (m *Manager) HasVersion(version *GoVersion) (bool, error) {
+	return m.HasVersionContext(context.Background(), version)
+}
+
+// HasVersionContext is like HasVersion but returns ctx.Err() if ctx is done.
+func (m *Manager) HasVersionContext(ctx context.Context, version *GoVersion) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	return existsDir(m.VersionGoROOT(version))
 }
 
@@ -233,70 +397,142 @@ func (m *Manager) versionDir(version *GoVersion) string {
 	return fmt.Sprintf("go%v.%v.%v", version, m.GOOS, m.GOARCH)
 }
 
+// Build builds the version from source using the providers that build from
+// source.
 func (m *Manager) Build(version *GoVersion) (string, error) {
+	return m.BuildContext(context.Background(), version)
+}
+
+// BuildContext is like Build but stops the build when ctx is done. Partial
+// builds are removed.
+func (m *Manager) BuildContext(ctx context.Context, version *GoVersion) (string, error) {
 	if version.IsTip() {
-		return m.ensureUpToDateTip()
+		return m.ensureUpToDateTip(ctx)
 	}
 
-	has, err := m.HasVersion(version)
+	has, err := m.HasVersionContext(ctx, version)
 	if err != nil {
 		return "", err
 	}
 	if has {
 		return m.VersionGoROOT(version), nil
 	}
+	if version.IsNamed() {
+		return "", fmt.Errorf("version %v is not installed, use BuildRef to build it: %w", version, common.ErrNotFound)
+	}
 
-	return m.installSrc(version)
+	var source []Provider
+	for _, p := range m.providers() {
+		if p.Source() {
+			source = append(source, p)
+		}
+	}
+	return shareFlight(ctx, &m.flights, "build:"+m.versionDir(version), func(ctx context.Context) (string, error) {
+		return m.installLocked(ctx, version, func() (string, error) {
+			return m.installFrom(ctx, source, version)
+		})
+	})
 }
 
+// Install installs the version from the first provider that has it.
 func (m *Manager) Install(version *GoVersion) (string, error) {
+	return m.InstallContext(context.Background(), version)
+}
+
+// InstallContext is like Install but stops when ctx is done. Partial
+// installations are removed.
+func (m *Manager) InstallContext(ctx context.Context, version *GoVersion) (string, error) {
 	if version.IsTip() {
-		return m.ensureUpToDateTip()
+		return m.ensureUpToDateTip(ctx)
 	}
 
-	has, err := m.HasVersion(version)
+	has, err := m.HasVersionContext(ctx, version)
 	if err != nil {
 		return "", err
 	}
 	if has {
 		return m.VersionGoROOT(version), nil
 	}
+	if version.IsNamed() {
+		return "", fmt.Errorf("version %v is not installed, use BuildRef to build it: %w", version, common.ErrNotFound)
+	}
+
+	return shareFlight(ctx, &m.flights, "install:"+m.versionDir(version), func(ctx context.Context) (string, error) {
+		return m.installLocked(ctx, version, func() (string, error) {
+			return m.installFrom(ctx, m.providers(), version)
+		})
+	})
+}
+
+// versionLock returns the name of the lock for installing the version.
+func (m *Manager) versionLock(version *GoVersion) string {
+	return "version-" + m.versionDir(version)
+}
 
-	if tryBinary := !version.IsTip(); tryBinary {
-		dir, err := m.installBinary(version)
-		if err == nil {
-			return dir, nil
+// installLocked runs install while holding the lock for the version. If
+// another process installed the version while this one waited for the lock
+// its installation is used.
+func (m *Manager) installLocked(ctx context.Context, version *GoVersion, install func() (string, error)) (string, error) {
+	var dir string
+	err := m.withLock(ctx, m.versionLock(version), func() error {
+		has, err := m.HasVersionContext(ctx, version)
+		if err != nil {
+			return err
 		}
-		// Only continue to installing from source if the server confirms 404.
-		if !errors.Is(err, common.ErrNotFound) {
-			return "", err
+		if has {
+			m.Logger.Debugf("Version %v was installed by another process.", version)
+			dir = m.VersionGoROOT(version)
+			return nil
 		}
-		m.Logger.Debug("Binary release not found on server. Trying to install from source.")
-	}
-
-	return m.installSrc(version)
+		dir, err = install()
+		return err
+	})
+	return dir, err
 }
 
-func (m *Manager) ensureUpToDateTip() (string, error) {
+func (m *Manager) ensureUpToDateTip(ctx context.Context) (string, error) {
 	version, _ := ParseVersion("tip")
 
-	has, err := m.HasVersion(version)
-	if err != nil {
-		return "", err
-	}
+	return shareFlight(ctx, &m.flights, "tip", func(ctx context.Context) (string, error) {
+		var dir string
+		err := m.withLock(ctx, m.versionLock(version), func() error {
+			var err error
+			dir, err = m.updateTip(ctx, version)
+			return err
+		})
+		return dir, err
+	})
+}
 
-	// no updates since last build -> return installed version
-	if has {
-		updates, err := m.tryRefreshSrcCache()
+// updateTip builds tip if it is not installed or the source cache has new
+// commits. The caller must hold the version lock. The git commands that check
+// for new commits are written to the build log, which is removed if tip is up
+// to date.
+func (m *Manager) updateTip(ctx context.Context, version *GoVersion) (string, error) {
+	var dir string
+	err := m.withBuildLog(ctx, version, func(ctx context.Context) error {
+		has, err := m.HasVersionContext(ctx, version)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		if !updates {
-			return m.VersionGoROOT(version), nil
+		// no updates since last build -> return installed version
+		if has {
+			updates, err := m.tryRefreshSrcCache(ctx)
+			if err != nil {
+				return err
+			}
+
+			if !updates {
+				discardBuildLog(ctx)
+				dir = m.VersionGoROOT(version)
+				return nil
+			}
 		}
-	}
 
-	// new updates in cache -> rebuild
-	return m.installSrc(version)
+		// new updates in cache -> rebuild
+		dir, err = m.installSrc(ctx, version)
+		return err
+	})
+	return dir, err
 }
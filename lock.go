@@ -0,0 +1,124 @@
+package gvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// ErrLockTimeout is returned when a lock held by another process is not
+// released within the Manager's LockTimeout.
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// errLockHeld is returned by tryLockFile when another process holds the lock.
+var errLockHeld = errors.New("lock held")
+
+// lockPollInterval is how often a process waiting for a lock checks if it was
+// released.
+var lockPollInterval = 250 * time.Millisecond
+
+// lockInfo is written to a lock file to identify its holder.
+type lockInfo struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Created time.Time `json:"created"`
+}
+
+// fileLock is a lock file held by this process.
+type fileLock struct {
+	f *os.File
+}
+
+// lock acquires the lock with the given name under Home/locks. If another
+// process holds the lock it waits until the lock is released, LockTimeout
+// expires, or ctx is done. Locks are OS advisory locks on the file so they are
+// released by the OS when their holder exits, even if it crashed.
+func (m *Manager) lock(ctx context.Context, name string) (*fileLock, error) {
+	path := filepath.Join(m.locksDir, lockFileName(name))
+	host, _ := os.Hostname()
+	info, err := json.Marshal(lockInfo{PID: os.Getpid(), Host: host, Created: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	var deadline time.Time
+	if m.LockTimeout > 0 {
+		deadline = time.Now().Add(m.LockTimeout)
+	}
+	logged := false
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			// The holder info is only used in messages so errors are ignored.
+			if f.Truncate(0) == nil {
+				_, _ = f.WriteAt(info, 0)
+			}
+			return &fileLock{f: f}, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %v: %w", path, err)
+		}
+
+		holder := readLockHolder(path)
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w %v held by %v", ErrLockTimeout, path, holder)
+		}
+		if !logged {
+			m.Logger.WithField("lock", path).Infof("Waiting for %v to release lock.", holder)
+			logged = true
+		}
+		if err := common.SleepContext(ctx, lockPollInterval); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+}
+
+// withLock runs fn while holding the named lock.
+func (m *Manager) withLock(ctx context.Context, name string, fn func() error) error {
+	l, err := m.lock(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+	return fn()
+}
+
+// lockFileName returns a file name for the lock that is safe on all
+// platforms.
+func lockFileName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name) + ".lock"
+}
+
+// readLockHolder returns a description of the holder of the lock file.
+func readLockHolder(path string) string {
+	var info lockInfo
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		// The holder may not have written its info yet.
+		return "unknown process"
+	}
+	return fmt.Sprintf("pid %d on %v", info.PID, info.Host)
+}
+
+// unlock releases the lock. The lock file is kept because removing it would
+// let another process lock the removed file while a third creates a new one.
+func (l *fileLock) unlock() {
+	_ = l.f.Truncate(0)
+	_ = unlockFile(l.f)
+	l.f.Close()
+}
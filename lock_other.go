@@ -0,0 +1,12 @@
+//go:build !(unix && !aix) && !windows
+
+package gvm
+
+import "os"
+
+// tryLockFile does nothing because file locks are not supported on this
+// platform. Concurrent gvm processes are not serialized.
+func tryLockFile(f *os.File) error { return nil }
+
+// unlockFile does nothing because file locks are not supported.
+func unlockFile(f *os.File) error { return nil }
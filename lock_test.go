@@ -0,0 +1,88 @@
+package gvm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallWaitsForLock(t *testing.T) {
+	m := newTestManager(t, "")
+	empty := &emptyProvider{}
+	m.Providers = []Provider{empty}
+	version := MustParseVersion("1.22.5")
+
+	// Another process is installing the version.
+	other := &Manager{Home: m.Home, GOOS: m.GOOS, GOARCH: m.GOARCH, Logger: m.Logger}
+	require.NoError(t, other.Init())
+	l, err := other.lock(context.Background(), other.versionLock(version))
+	require.NoError(t, err)
+
+	type result struct {
+		dir string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		dir, err := m.Install(version)
+		done <- result{dir, err}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Install did not wait for the lock")
+	case <-time.After(2 * lockPollInterval):
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(other.VersionGoROOT(version), "bin"), 0o755))
+	l.unlock()
+
+	r := <-done
+	require.NoError(t, r.err)
+	assert.Equal(t, m.VersionGoROOT(version), r.dir)
+	assert.Zero(t, empty.fetches)
+}
+
+func TestLockTimeout(t *testing.T) {
+	m := newTestManager(t, "")
+	m.LockTimeout = 100 * time.Millisecond
+
+	l, err := m.lock(context.Background(), "test")
+	require.NoError(t, err)
+	defer l.unlock()
+
+	_, err = m.lock(context.Background(), "test")
+	assert.ErrorIs(t, err, ErrLockTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.LockTimeout = -1
+	_, err = m.lock(ctx, "test")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLockReleasedOnClose(t *testing.T) {
+	m := newTestManager(t, "")
+	m.LockTimeout = time.Second
+	path := filepath.Join(m.locksDir, lockFileName("test"))
+
+	// A lock file left behind by a process that exited does not block.
+	data, err := json.Marshal(lockInfo{PID: 1, Host: "other", Created: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	l, err := m.lock(context.Background(), "test")
+	require.NoError(t, err)
+
+	// The OS releases the lock when its holder closes the file, as when the
+	// holder crashes without unlocking.
+	require.NoError(t, l.f.Close())
+	l, err = m.lock(context.Background(), "test")
+	require.NoError(t, err)
+	l.unlock()
+}
@@ -0,0 +1,30 @@
+package gvm
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffsetHigh is the high word of the start of the locked byte range. It is past the end of
+// the file because Windows locks are mandatory and would otherwise prevent
+// waiting processes from reading the holder info.
+const lockOffsetHigh = 1
+
+// tryLockFile takes an exclusive lock on the file without waiting.
+func tryLockFile(f *os.File) error {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLockHeld
+	}
+	return err
+}
+
+// unlockFile releases the lock on the file.
+func unlockFile(f *os.File) error {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
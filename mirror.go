@@ -0,0 +1,284 @@
+package gvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// MirrorOptions selects the release files copied by MirrorSync.
+type MirrorOptions struct {
+	// Versions are the version specifiers to mirror. Exact and symbolic
+	// specifiers select a single release while constraints (e.g. ">=1.22")
+	// select every matching release. Required.
+	Versions []string
+
+	// Platforms are the GOOS/GOARCH pairs to mirror (e.g. linux/amd64). Either
+	// half may be "*" to match everything. Defaults to the Manager's GOOS and
+	// GOARCH.
+	Platforms []string
+
+	// Kinds are the kinds of files to mirror (archive, installer, source).
+	// Defaults to archive.
+	Kinds []string
+
+	// Progress, if not nil, is called with the name of each file before it is
+	// downloaded.
+	Progress func(filename string)
+}
+
+// MirrorResult describes the changes made by MirrorSync.
+type MirrorResult struct {
+	Downloaded []string // Files that were downloaded.
+	UpToDate   []string // Files that already existed with the expected checksum.
+}
+
+// MirrorSync downloads the release files selected by opts from GoStorageHome
+// into dir and writes an index.json that GoStorageHome can point at. Files
+// that already exist with the expected checksum are not downloaded again, and
+// releases already in the mirror's index are kept.
+func (m *Manager) MirrorSync(dir string, opts MirrorOptions) (*MirrorResult, error) {
+	return m.MirrorSyncContext(context.Background(), dir, opts)
+}
+
+// MirrorSyncContext is like MirrorSync but stops when ctx is done. Files that
+// were completely downloaded are kept and reused by the next sync.
+func (m *Manager) MirrorSyncContext(ctx context.Context, dir string, opts MirrorOptions) (*MirrorResult, error) {
+	if len(opts.Versions) == 0 {
+		return nil, errors.New("no versions specified")
+	}
+	if len(opts.Platforms) == 0 {
+		opts.Platforms = []string{m.GOOS + "/" + m.GOARCH}
+	}
+	if len(opts.Kinds) == 0 {
+		opts.Kinds = []string{"archive"}
+	}
+	for _, p := range opts.Platforms {
+		if _, _, ok := strings.Cut(p, "/"); !ok {
+			return nil, fmt.Errorf("invalid platform %q, expected GOOS/GOARCH", p)
+		}
+	}
+
+	releases, err := m.fetchGoReleases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch releases: %w", err)
+	}
+
+	selected, err := selectMirrorReleases(releases, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(dir, ".sync-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	result := &MirrorResult{}
+	for _, release := range selected {
+		for _, file := range release.Files {
+			name := filepath.Base(file.Filename)
+			dest := filepath.Join(dir, name)
+
+			if err := common.VerifyFile(dest, file.Size, file.SHA256); err == nil {
+				result.UpToDate = append(result.UpToDate, name)
+				continue
+			} else if !os.IsNotExist(err) {
+				m.Logger.WithError(err).Infof("Replacing mirrored file %v.", name)
+			}
+
+			if m.Offline {
+				return result, fmt.Errorf("cannot download %v: %w", name, ErrOffline)
+			}
+			if opts.Progress != nil {
+				opts.Progress(name)
+			}
+
+			downloaded, err := m.downloadArchive(ctx, &file, tmp)
+			if err != nil {
+				return result, err
+			}
+			if err := os.Rename(downloaded, dest); err != nil {
+				return result, err
+			}
+			result.Downloaded = append(result.Downloaded, name)
+		}
+	}
+
+	if err := writeMirrorIndex(dir, selected); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// selectMirrorReleases returns the releases matching opts. Each release only
+// contains the files matching the platforms and kinds in opts.
+func selectMirrorReleases(releases []GoRelease, opts MirrorOptions) ([]GoRelease, error) {
+	candidates := make([]candidate, 0, len(releases))
+	byVersion := make(map[string]GoRelease, len(releases))
+	for _, release := range releases {
+		ver, err := ParseVersion(strings.TrimPrefix(release.Version, "go"))
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{version: ver, stable: release.Stable})
+		byVersion[ver.String()] = release
+	}
+
+	versions := map[string]struct{}{}
+	for _, spec := range opts.Versions {
+		spec = strings.TrimPrefix(strings.TrimSpace(spec), "go")
+
+		switch {
+		case isConstraintSpec(spec):
+			constraints, err := ParseConstraints(spec)
+			if err != nil {
+				return nil, err
+			}
+			matched := false
+			for _, c := range candidates {
+				if constraints.Check(c.version) {
+					versions[c.version.String()] = struct{}{}
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("no version matching %q found", spec)
+			}
+		case isSymbolicSpec(spec):
+			ver, err := selectVersion(spec, candidates)
+			if err != nil {
+				return nil, err
+			}
+			versions[ver.String()] = struct{}{}
+		default:
+			ver, err := ParseVersion(spec)
+			if err != nil {
+				return nil, err
+			}
+			if _, found := byVersion[ver.String()]; !found {
+				return nil, fmt.Errorf("version %v not found", ver)
+			}
+			versions[ver.String()] = struct{}{}
+		}
+	}
+
+	selected := make([]GoRelease, 0, len(versions))
+	for v := range versions {
+		release := byVersion[v]
+
+		var files []GoFile
+		for _, file := range release.Files {
+			if matchMirrorFile(file, opts) {
+				files = append(files, file)
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+		release.Files = files
+		selected = append(selected, release)
+	}
+	sortReleases(selected)
+	return selected, nil
+}
+
+func matchMirrorFile(file GoFile, opts MirrorOptions) bool {
+	kindMatch := false
+	for _, kind := range opts.Kinds {
+		kindMatch = kindMatch || file.Kind == kind
+	}
+	if !kindMatch {
+		return false
+	}
+
+	// Source archives are not specific to a platform.
+	if file.Kind == "source" {
+		return true
+	}
+
+	for _, p := range opts.Platforms {
+		goos, goarch, _ := strings.Cut(p, "/")
+		if goarch == "arm" {
+			// Binary releases for ARM are only available for ARMv6.
+			goarch = "armv6l"
+		}
+		if matched, _ := path.Match(goos, file.OS); !matched {
+			continue
+		}
+		if matched, _ := path.Match(goarch, file.Arch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// writeMirrorIndex merges releases into the mirror's index.json. Files that
+// are already listed in the index are kept.
+func writeMirrorIndex(dir string, releases []GoRelease) error {
+	var index []GoRelease
+	indexFile := filepath.Join(dir, localIndexFile)
+	if err := readJSONFile(indexFile, &index); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read mirror index: %w", err)
+	}
+
+	positions := make(map[string]int, len(index)+len(releases))
+	for i := range index {
+		positions[index[i].Version] = i
+	}
+	for _, release := range releases {
+		i, found := positions[release.Version]
+		if !found {
+			positions[release.Version] = len(index)
+			index = append(index, release)
+			continue
+		}
+		existing := &index[i]
+		existing.Stable = release.Stable
+		for _, file := range release.Files {
+			replaced := false
+			for j := range existing.Files {
+				if existing.Files[j].Filename == file.Filename {
+					existing.Files[j] = file
+					replaced = true
+				}
+			}
+			if !replaced {
+				existing.Files = append(existing.Files, file)
+			}
+		}
+	}
+
+	sortReleases(index)
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(indexFile, data)
+}
+
+// sortReleases orders releases newest first like the go.dev API. Releases with
+// unparsable versions are moved to the end.
+func sortReleases(releases []GoRelease) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		vi, erri := ParseVersion(strings.TrimPrefix(releases[i].Version, "go"))
+		vj, errj := ParseVersion(strings.TrimPrefix(releases[j].Version, "go"))
+		if erri != nil || errj != nil {
+			return errj != nil && erri == nil
+		}
+		return vj.LessThan(vi)
+	})
+}
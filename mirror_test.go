@@ -0,0 +1,42 @@
+package gvm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorSync(t *testing.T) {
+	upstream := t.TempDir()
+	writeTestMirror(t, upstream, "1.21.13", "1.22.4", "1.22.5")
+
+	m := newTestManager(t, upstream)
+	dir := filepath.Join(t.TempDir(), "mirror")
+
+	result, err := m.MirrorSync(dir, MirrorOptions{Versions: []string{"~1.22"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"go1.22.4.linux-amd64.tar.gz", "go1.22.5.linux-amd64.tar.gz"}, result.Downloaded)
+
+	// Syncing again only fetches new files and keeps existing index entries.
+	result, err = m.MirrorSync(dir, MirrorOptions{Versions: []string{"1.21.13", "1.22.5"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go1.21.13.linux-amd64.tar.gz"}, result.Downloaded)
+	assert.Equal(t, []string{"go1.22.5.linux-amd64.tar.gz"}, result.UpToDate)
+
+	// Other platforms are not mirrored.
+	result, err = m.MirrorSync(dir, MirrorOptions{Versions: []string{"1.22.5"}, Platforms: []string{"darwin/*"}})
+	require.NoError(t, err)
+	assert.Empty(t, result.Downloaded)
+	assert.Empty(t, result.UpToDate)
+
+	// The mirror can be used as the storage home.
+	client := newTestManager(t, dir)
+	versions, err := client.AvailableBinaries()
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+
+	_, err = client.Install(MustParseVersion("1.21.13"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,357 @@
+package gvm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// toolchainModule is the module that Go releases are published as on module
+// proxies. Each release is a version like v0.0.1-go1.22.5.linux-amd64.
+const toolchainModule = "golang.org/toolchain"
+
+// defaultGOPROXY is used when neither Manager.GOPROXY nor $GOPROXY is set.
+const defaultGOPROXY = "https://proxy.golang.org,direct"
+
+// proxyEntry is one element of a GOPROXY list.
+type proxyEntry struct {
+	url string
+	// fallbackOnError means the next proxy is tried after any error rather
+	// than only after a 404 or 410 response (the | separator).
+	fallbackOnError bool
+}
+
+// goproxy returns the GOPROXY value in effect.
+func (m *Manager) goproxy() string {
+	goproxy := m.GOPROXY
+	if goproxy == "" {
+		goproxy = os.Getenv("GOPROXY")
+	}
+	if goproxy == "" {
+		goproxy = defaultGOPROXY
+	}
+	return goproxy
+}
+
+// goproxyList returns the module proxies to query. "direct" entries are
+// skipped because toolchain modules can only be fetched from a proxy.
+func (m *Manager) goproxyList() ([]proxyEntry, error) {
+	goproxy := m.goproxy()
+
+	var proxies []proxyEntry
+	for goproxy != "" {
+		var entry proxyEntry
+		if i := strings.IndexAny(goproxy, ",|"); i >= 0 {
+			entry = proxyEntry{url: goproxy[:i], fallbackOnError: goproxy[i] == '|'}
+			goproxy = goproxy[i+1:]
+		} else {
+			entry = proxyEntry{url: goproxy}
+			goproxy = ""
+		}
+
+		entry.url = strings.TrimSuffix(strings.TrimSpace(entry.url), "/")
+		switch entry.url {
+		case "":
+			continue
+		case "off":
+			goproxy = ""
+			continue
+		case "direct", "noproxy":
+			continue
+		}
+		proxies = append(proxies, entry)
+	}
+	if len(proxies) == 0 {
+		return nil, errors.New("GOPROXY does not contain a module proxy")
+	}
+	return proxies, nil
+}
+
+// toolchainVersion returns the golang.org/toolchain module version of a Go
+// release.
+func (m *Manager) toolchainVersion(version *GoVersion) string {
+	return fmt.Sprintf("v0.0.1-go%v%v", version, m.toolchainSuffix())
+}
+
+// toolchainSuffix returns the GOOS-GOARCH suffix of toolchain module versions.
+// Unlike the archives, toolchain modules use the GOARCH name for ARM.
+func (m *Manager) toolchainSuffix() string {
+	goarch := m.GOARCH
+	if goarch == "armv6l" {
+		goarch = "arm"
+	}
+	return fmt.Sprintf(".%v-%v", m.GOOS, goarch)
+}
+
+// proxyGet requests the path from each proxy in turn. It returns the response
+// from the first proxy that has the file. ErrNotFound is returned if no proxy
+// has it.
+func (m *Manager) proxyGet(ctx context.Context, p string) (*http.Response, error) {
+	proxies, err := m.goproxyList()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proxy := range proxies {
+		resp, err := m.getURL(ctx, proxy.url+p)
+		switch {
+		case err == nil:
+			return resp, nil
+		case errors.Is(err, common.ErrNotFound):
+			continue
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case proxy.fallbackOnError:
+			m.Logger.WithError(err).Debugf("Module proxy %v failed, trying next.", proxy.url)
+			continue
+		default:
+			return nil, err
+		}
+	}
+	return nil, common.ErrNotFound
+}
+
+// getURL fetches a file from a module proxy or checksum database, retrying
+// failed requests. file:// URLs are read from disk. ErrNotFound is returned
+// for missing files and 404 or 410 responses.
+func (m *Manager) getURL(ctx context.Context, rawURL string) (*http.Response, error) {
+	if path, ok := common.FileURLPath(rawURL); ok {
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, common.ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: f, ContentLength: -1}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := m.httpClient()
+	var resp *http.Response
+	err = m.Retry.Do(ctx, func() (bool, error) {
+		r, err := client.Do(req)
+		if err != nil {
+			return true, err
+		}
+		if r.StatusCode == http.StatusNotFound || r.StatusCode == http.StatusGone {
+			r.Body.Close()
+			return false, common.ErrNotFound
+		}
+		if retryable, err := common.CheckResponse(r); err != nil {
+			r.Body.Close()
+			return retryable, fmt.Errorf("module proxy request failed: %w", err)
+		}
+		resp = r
+		return false, nil
+	})
+	return resp, err
+}
+
+// availableModuleProxy lists the Go releases published as toolchain modules
+// for the Manager's GOOS and GOARCH.
+func (m *Manager) availableModuleProxy(ctx context.Context) ([]*GoVersion, error) {
+	if m.Offline {
+		return nil, fmt.Errorf("cannot list toolchain modules: %w", ErrOffline)
+	}
+
+	resp, err := m.proxyGet(ctx, "/"+toolchainModule+"/@v/list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %v versions: %w", toolchainModule, err)
+	}
+	defer resp.Body.Close()
+
+	suffix := m.toolchainSuffix()
+	var versions []*GoVersion
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "v0.0.1-go") || !strings.HasSuffix(line, suffix) {
+			continue
+		}
+		ver, err := ParseVersion(strings.TrimSuffix(strings.TrimPrefix(line, "v0.0.1-go"), suffix))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, ver)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	sortVersions(versions)
+	return versions, nil
+}
+
+// fetchModuleProxy returns the path to the verified toolchain module zip for
+// the version in the archives cache, downloading it from the module proxy if
+// it is not already cached. The hash of a zip verified by the checksum
+// database is kept in a .ziphash file next to it, so a cached zip is checked
+// against that hash without network access.
+func (m *Manager) fetchModuleProxy(ctx context.Context, version *GoVersion) (string, error) {
+	modVersion := m.toolchainVersion(version)
+	name := "toolchain@" + modVersion + ".zip"
+
+	l, err := m.lock(ctx, "archive-"+name)
+	if err != nil {
+		return "", err
+	}
+	defer l.unlock()
+
+	zipFile := filepath.Join(m.archivesDir, name)
+	hashFile := zipFile + ".ziphash"
+	if want, err := os.ReadFile(hashFile); err == nil {
+		got, err := dirhash.HashZip(zipFile, dirhash.Hash1)
+		if err == nil && got == strings.TrimSpace(string(want)) {
+			m.Logger.WithField("file", zipFile).Debug("Using cached toolchain module.")
+			return zipFile, nil
+		}
+		m.Logger.WithError(err).Info("Discarding cached toolchain module that failed verification.")
+	}
+	// Zips without a verified hash are never reused.
+	for _, path := range []string{hashFile, zipFile} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if m.Offline {
+		return "", fmt.Errorf("toolchain module %v@%v is not cached: %w", toolchainModule, modVersion, ErrOffline)
+	}
+
+	resp, err := m.proxyGet(ctx, "/"+toolchainModule+"/@v/"+modVersion+".zip")
+	if err != nil {
+		return "", err
+	}
+	err = writeResponse(zipFile, resp, m.downloadProgress(zipFile, 0))
+	if err != nil {
+		os.Remove(zipFile)
+		return "", fmt.Errorf("failed downloading %v@%v: %w", toolchainModule, modVersion, err)
+	}
+
+	hash, err := m.verifyToolchainZip(ctx, zipFile, modVersion)
+	if err != nil {
+		os.Remove(zipFile)
+		return "", err
+	}
+	if hash != "" {
+		if err := writeFileAtomic(hashFile, []byte(hash+"\n")); err != nil {
+			return "", err
+		}
+	}
+	return zipFile, nil
+}
+
+// verifyToolchainZip checks the hash of a toolchain module zip against the
+// checksum database configured by GOSUMDB. As in cmd/go, verification of
+// golang.org/toolchain is mandatory unless GOPROXY is a single file:// URL.
+// Only then may GOSUMDB=off or GONOSUMDB (defaulting to GOPRIVATE) skip it.
+// It returns the verified hash, or an empty string if verification is off.
+func (m *Manager) verifyToolchainZip(ctx context.Context, zipFile, modVersion string) (string, error) {
+	gosumdb := os.Getenv("GOSUMDB")
+	if goproxy := m.goproxy(); strings.HasPrefix(goproxy, "file://") && !strings.ContainsAny(goproxy, ",|") {
+		nosumdb := os.Getenv("GONOSUMDB")
+		if nosumdb == "" {
+			nosumdb = os.Getenv("GOPRIVATE")
+		}
+		if gosumdb == "off" || module.MatchPrefixPatterns(nosumdb, toolchainModule) {
+			m.Logger.Warnf("Not verifying %v@%v from local GOPROXY %v.", toolchainModule, modVersion, goproxy)
+			return "", nil
+		}
+	} else if gosumdb == "off" {
+		return "", fmt.Errorf("cannot verify %v@%v: GOSUMDB=off, but toolchain "+
+			"modules must be verified by the checksum database unless GOPROXY "+
+			"is a single file:// URL", toolchainModule, modVersion)
+	}
+
+	db, err := m.newSumDBOps(ctx, gosumdb)
+	if err != nil {
+		return "", err
+	}
+
+	lines, err := db.lookup(toolchainModule, modVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %v@%v in checksum database: %w", toolchainModule, modVersion, err)
+	}
+
+	var want string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == toolchainModule && fields[1] == modVersion {
+			want = fields[2]
+		}
+	}
+	if want == "" {
+		return "", fmt.Errorf("checksum database has no hash for %v@%v", toolchainModule, modVersion)
+	}
+
+	got, err := dirhash.HashZip(zipFile, dirhash.Hash1)
+	if err != nil {
+		return "", err
+	}
+	if got != want {
+		return "", &common.ChecksumError{File: zipFile, ExpectedSHA256: want, ActualSHA256: got}
+	}
+	m.Logger.Debugf("Verified %v@%v against %v.", toolchainModule, modVersion, db.name)
+	return got, nil
+}
+
+// markToolchainExecutables makes the files in bin and pkg/tool executable
+// because module zips do not record file modes.
+func markToolchainExecutables(goroot string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	for _, dir := range []string{"bin", filepath.Join("pkg", "tool")} {
+		err := filepath.WalkDir(filepath.Join(goroot, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			return os.Chmod(path, 0o755)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeResponse writes the body of resp to the file and closes the body. If
+// progress is not nil it is called as the body is read.
+func writeResponse(filename string, resp *http.Response, progress func(downloaded, total int64)) error {
+	defer resp.Body.Close()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	body := &progressReader{r: resp.Body, progress: progress, total: resp.ContentLength}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
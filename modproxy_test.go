@@ -0,0 +1,255 @@
+package gvm
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+const testToolchainVersion = "v0.0.1-go1.22.5.linux-amd64"
+
+// writeToolchainZip writes a toolchain module zip and returns its h1 hash.
+func writeToolchainZip(t *testing.T, path, modVersion string) string {
+	t.Helper()
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	prefix := toolchainModule + "@" + modVersion + "/"
+	for name, content := range map[string]string{
+		"VERSION": "go1.22.5\n",
+		"bin/go":  "#!/bin/sh\n",
+	} {
+		w, err := zw.Create(prefix + name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	h, err := dirhash.HashZip(path, dirhash.Hash1)
+	require.NoError(t, err)
+	return h
+}
+
+// testModuleProxy is a module proxy serving a toolchain zip and a checksum
+// database, signed with a generated key, that records wantHash for it.
+type testModuleProxy struct {
+	URL      string
+	GOSUMDB  string // GOSUMDB value for the checksum database.
+	signer   string
+	wantHash string
+
+	mu sync.Mutex
+	db *sumdb.Server
+}
+
+// newTestModuleProxy returns a module proxy serving the toolchain zip and a
+// checksum database that records wantHash for every toolchain version.
+func newTestModuleProxy(t *testing.T, zipFile, wantHash string) *testModuleProxy {
+	t.Helper()
+
+	const dbName = "sum.example.com"
+	signer, verifier, err := note.GenerateKey(rand.Reader, dbName)
+	require.NoError(t, err)
+	p := &testModuleProxy{signer: signer, wantHash: wantHash}
+	p.resetSumDB(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/golang.org/toolchain/@v/list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "v0.0.1-go1.21.0.linux-amd64")
+		fmt.Fprintln(w, "v0.0.1-go1.22.5.darwin-arm64")
+		fmt.Fprintln(w, testToolchainVersion)
+	})
+	mux.HandleFunc("/golang.org/toolchain/@v/"+testToolchainVersion+".zip", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, zipFile)
+	})
+	mux.HandleFunc("/sumdb/"+dbName+"/supported", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/sumdb/"+dbName+"/", http.StripPrefix("/sumdb/"+dbName, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		db := p.db
+		p.mu.Unlock()
+		db.ServeHTTP(w, r)
+	})))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	for _, env := range []string{"GONOSUMDB", "GOPRIVATE"} {
+		t.Setenv(env, "")
+	}
+	p.URL = srv.URL
+	// Requests go through the proxy so the database URL is never used.
+	p.GOSUMDB = verifier + " https://" + dbName + ".invalid"
+	return p
+}
+
+// resetSumDB replaces the checksum database with a new one signed by the same
+// key that first records the given modules. This forks the log seen by
+// clients of the previous database.
+func (p *testModuleProxy) resetSumDB(t *testing.T, records ...module.Version) {
+	t.Helper()
+
+	ts := sumdb.NewTestServer(p.signer, func(path, vers string) ([]byte, error) {
+		if path == toolchainModule {
+			return []byte(fmt.Sprintf("%v %v %v\n", path, vers, p.wantHash)), nil
+		}
+		return []byte(fmt.Sprintf("%v %v h1:%v=\n", path, vers, strings.Repeat("A", 43))), nil
+	})
+	for _, r := range records {
+		_, err := ts.Lookup(context.Background(), r)
+		require.NoError(t, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.db = sumdb.NewServer(ts)
+}
+
+func TestInstallModuleProxy(t *testing.T) {
+	zipFile := filepath.Join(t.TempDir(), "toolchain.zip")
+	h := writeToolchainZip(t, zipFile, testToolchainVersion)
+	proxy := newTestModuleProxy(t, zipFile, h)
+	t.Setenv("GOSUMDB", proxy.GOSUMDB)
+
+	m := newTestManager(t, "")
+	m.UseModuleProxy = true
+	m.GOPROXY = proxy.URL
+
+	versions, err := m.AvailableBinaries()
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "1.21.0", versions[0].String())
+	assert.Equal(t, "1.22.5", versions[1].String())
+
+	ver, err := m.ResolveVersion("stable")
+	require.NoError(t, err)
+	assert.Equal(t, "1.22.5", ver.String())
+
+	goroot, err := m.Install(ver)
+	require.NoError(t, err)
+	assert.Equal(t, m.VersionGoROOT(ver), goroot)
+	assert.FileExists(t, filepath.Join(goroot, "VERSION"))
+
+	info, err := os.Stat(filepath.Join(goroot, "bin", "go"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0o100, "bin/go should be executable")
+
+	// The tree head is kept so later runs detect a forked database.
+	assert.FileExists(t, filepath.Join(m.Home, "sumdb", "sum.example.com", "latest"))
+
+	// The verified zip is cached and reinstalled without network access.
+	require.NoError(t, os.RemoveAll(goroot))
+	m.Offline = true
+	goroot, err = m.Install(ver)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(goroot, "VERSION"))
+}
+
+func TestVerifyToolchainZipDetectsForkedSumDB(t *testing.T) {
+	zipFile := filepath.Join(t.TempDir(), "toolchain.zip")
+	h := writeToolchainZip(t, zipFile, testToolchainVersion)
+	proxy := newTestModuleProxy(t, zipFile, h)
+	t.Setenv("GOSUMDB", proxy.GOSUMDB)
+
+	m := newTestManager(t, "")
+	m.GOPROXY = proxy.URL
+	_, err := m.verifyToolchainZip(context.Background(), zipFile, testToolchainVersion)
+	require.NoError(t, err)
+
+	proxy.resetSumDB(t, module.Version{Path: "example.com/a", Version: "v1.0.0"})
+
+	// Only the tree head stored under Home is needed to detect the fork.
+	require.NoError(t, os.RemoveAll(filepath.Join(m.cacheDir, "sumdb")))
+	_, err = m.verifyToolchainZip(context.Background(), zipFile, "v0.0.1-go1.21.0.linux-amd64")
+	assert.ErrorContains(t, err, "inconsistent")
+}
+
+func TestVerifyToolchainZipRequiresSumDB(t *testing.T) {
+	zipFile := filepath.Join(t.TempDir(), "toolchain.zip")
+	writeToolchainZip(t, zipFile, testToolchainVersion)
+	proxy := newTestModuleProxy(t, zipFile, "h1:"+strings.Repeat("A", 43)+"=")
+
+	m := newTestManager(t, "")
+	m.GOPROXY = proxy.URL
+
+	t.Run("GOSUMDB=off", func(t *testing.T) {
+		t.Setenv("GOSUMDB", "off")
+		_, err := m.verifyToolchainZip(context.Background(), zipFile, testToolchainVersion)
+		assert.ErrorContains(t, err, "GOSUMDB=off")
+	})
+
+	t.Run("GONOSUMDB", func(t *testing.T) {
+		t.Setenv("GOSUMDB", proxy.GOSUMDB)
+		t.Setenv("GONOSUMDB", "golang.org")
+		_, err := m.verifyToolchainZip(context.Background(), zipFile, testToolchainVersion)
+		var checksumErr *common.ChecksumError
+		assert.ErrorAs(t, err, &checksumErr)
+	})
+}
+
+func TestInstallModuleProxyFileGOPROXY(t *testing.T) {
+	dir := t.TempDir()
+	versionDir := filepath.Join(dir, filepath.FromSlash(toolchainModule), "@v")
+	require.NoError(t, os.MkdirAll(versionDir, 0o755))
+	writeToolchainZip(t, filepath.Join(versionDir, testToolchainVersion+".zip"), testToolchainVersion)
+	proxyURL, err := common.FileURL(dir)
+	require.NoError(t, err)
+
+	// A single file:// GOPROXY may turn off the checksum database.
+	t.Setenv("GOSUMDB", "off")
+	m := newTestManager(t, "")
+	m.UseModuleProxy = true
+	m.GOPROXY = proxyURL
+
+	goroot, err := m.Install(MustParseVersion("1.22.5"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(goroot, "VERSION"))
+}
+
+func TestInstallModuleProxyHashMismatch(t *testing.T) {
+	zipFile := filepath.Join(t.TempDir(), "toolchain.zip")
+	writeToolchainZip(t, zipFile, testToolchainVersion)
+	proxy := newTestModuleProxy(t, zipFile, "h1:"+strings.Repeat("A", 43)+"=")
+	t.Setenv("GOSUMDB", proxy.GOSUMDB)
+
+	m := newTestManager(t, "")
+	m.UseModuleProxy = true
+	m.GOPROXY = proxy.URL
+
+	_, err := m.Install(MustParseVersion("1.22.5"))
+	var checksumErr *common.ChecksumError
+	require.ErrorAs(t, err, &checksumErr)
+	assert.NoDirExists(t, m.VersionGoROOT(MustParseVersion("1.22.5")))
+}
+
+func TestGoproxyList(t *testing.T) {
+	m := &Manager{GOPROXY: "https://a.example.com/,direct|https://b.example.com|off,https://c.example.com"}
+	proxies, err := m.goproxyList()
+	require.NoError(t, err)
+	assert.Equal(t, []proxyEntry{
+		{url: "https://a.example.com"},
+		{url: "https://b.example.com", fallbackOnError: true},
+	}, proxies)
+
+	m.GOPROXY = "direct"
+	_, err = m.goproxyList()
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package gvm
+
+import (
+	"io"
+	"path"
+)
+
+// ProgressPhase identifies the stage of an installation.
+type ProgressPhase string
+
+const (
+	PhaseDownload ProgressPhase = "download" // Downloading an archive.
+	PhaseExtract  ProgressPhase = "extract"  // Extracting an archive.
+	PhaseBuild    ProgressPhase = "build"    // Building Go from source.
+)
+
+// ProgressEvent reports the progress of an installation to Manager.Progress.
+type ProgressEvent struct {
+	Phase ProgressPhase
+
+	// File is the name of the archive being downloaded or extracted.
+	File string
+
+	// Step describes the current build step (e.g. "Building Go toolchain1").
+	// It is only set for PhaseBuild.
+	Step string
+
+	// Current is the number of bytes downloaded or files extracted.
+	Current int64
+
+	// Total is the size of the download in bytes or -1 if unknown. It is
+	// always -1 for extraction and builds.
+	Total int64
+}
+
+// reportProgress passes the event to the Progress callback, if set.
+func (m *Manager) reportProgress(ev ProgressEvent) {
+	if m.Progress != nil {
+		m.Progress(ev)
+	}
+}
+
+// downloadProgress returns a download progress callback that reports events
+// for the file. If size is greater than zero it is used as the total instead
+// of the size reported by the server.
+func (m *Manager) downloadProgress(url string, size int64) func(downloaded, total int64) {
+	if m.Progress == nil {
+		return nil
+	}
+	file := path.Base(url)
+	return func(downloaded, total int64) {
+		if size > 0 {
+			total = size
+		}
+		m.reportProgress(ProgressEvent{Phase: PhaseDownload, File: file, Current: downloaded, Total: total})
+	}
+}
+
+// extractProgress returns an extraction progress callback that reports events
+// for the archive.
+func (m *Manager) extractProgress(archive string) func(name string) {
+	if m.Progress == nil {
+		return nil
+	}
+	file := path.Base(archive)
+	var n int64
+	return func(string) {
+		n++
+		m.reportProgress(ProgressEvent{Phase: PhaseExtract, File: file, Current: n, Total: -1})
+	}
+}
+
+// buildProgress reports a build step.
+func (m *Manager) buildProgress(step string) {
+	m.reportProgress(ProgressEvent{Phase: PhaseBuild, Step: step, Total: -1})
+}
+
+// progressReader reports the bytes read from r to progress.
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	progress func(downloaded, total int64)
+	total    int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.progress != nil && n > 0 {
+		r.progress(r.n, r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,242 @@
+package gvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// Provider is a source of Go toolchains. A Manager installs versions from its
+// ordered list of providers, moving on to the next provider when one does not
+// have the requested version.
+type Provider interface {
+	// Name identifies the provider in log messages.
+	Name() string
+
+	// Source returns true if the provider builds Go from source rather than
+	// installing a binary release.
+	Source() bool
+
+	// List returns the versions that the provider can install for the
+	// Manager's GOOS and GOARCH.
+	List(ctx context.Context, m *Manager) ([]*GoVersion, error)
+
+	// Fetch returns the path of a binary distribution archive of the version
+	// for the Manager's GOOS and GOARCH: a .tar.gz or .zip file with a go
+	// directory, or a golang.org/toolchain module zip. The Manager extracts
+	// the archive to the version's GOROOT while holding the version's lock and
+	// leaves the archive in place. If the provider does not have the version
+	// it must return an error wrapping common.ErrNotFound.
+	Fetch(ctx context.Context, m *Manager, version *GoVersion) (string, error)
+}
+
+// builder is implemented by providers that build versions into their GOROOT
+// instead of fetching an archive. The Manager holds the version's lock.
+type builder interface {
+	build(ctx context.Context, m *Manager, version *GoVersion) (string, error)
+}
+
+// candidateLister is implemented by providers that know which of their
+// versions are stable releases.
+type candidateLister interface {
+	candidates(ctx context.Context, m *Manager) ([]candidate, error)
+}
+
+// providers returns the configured providers. By default binary releases are
+// installed from GoStorageHome (or the module proxy if UseModuleProxy is set)
+// with a fallback to building from source.
+func (m *Manager) providers() []Provider {
+	if m.Providers != nil {
+		return m.Providers
+	}
+
+	var binary Provider = BinaryProvider{}
+	if m.UseModuleProxy {
+		binary = ModuleProxyProvider{}
+	}
+	return []Provider{binary, SourceProvider{}}
+}
+
+// installFrom installs the version from the first of the providers that has
+// it. A provider is skipped if it does not have the version or, in offline
+// mode, if it needs network access. The caller must hold the version's lock.
+func (m *Manager) installFrom(ctx context.Context, providers []Provider, version *GoVersion) (string, error) {
+	if len(providers) == 0 {
+		return "", errors.New("no toolchain providers configured")
+	}
+
+	var err error
+	for _, p := range providers {
+		var dir string
+		if b, ok := p.(builder); ok {
+			dir, err = b.build(ctx, m, version)
+		} else {
+			dir, err = m.installFetched(ctx, p, version)
+		}
+		if err == nil {
+			return dir, nil
+		}
+
+		log := m.Logger.WithError(err).WithField("provider", p.Name())
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			log.Debugf("Version %v not found.", version)
+		case m.Offline && errors.Is(err, ErrOffline):
+			log.Debugf("Version %v not available offline.", version)
+		default:
+			return "", err
+		}
+	}
+	return "", err
+}
+
+// installFetched extracts the archive of the version fetched by the provider
+// to the version's GOROOT.
+func (m *Manager) installFetched(ctx context.Context, p Provider, version *GoVersion) (string, error) {
+	path, err := p.Fetch(ctx, m, version)
+	if err != nil {
+		return "", err
+	}
+	return m.extractTo(ctx, m.VersionGoROOT(version), path)
+}
+
+// BinaryProvider installs binary releases listed by the Go downloads API at
+// the Manager's GoStorageHome.
+type BinaryProvider struct{}
+
+func (BinaryProvider) Name() string { return "binary" }
+
+func (BinaryProvider) Source() bool { return false }
+
+func (BinaryProvider) List(ctx context.Context, m *Manager) ([]*GoVersion, error) {
+	return m.availableReleaseBinaries(ctx)
+}
+
+func (BinaryProvider) Fetch(ctx context.Context, m *Manager, version *GoVersion) (string, error) {
+	return m.fetchBinary(ctx, version)
+}
+
+func (BinaryProvider) candidates(ctx context.Context, m *Manager) ([]candidate, error) {
+	return m.releaseCandidates(ctx)
+}
+
+// SourceProvider builds releases from the git repository at the Manager's
+// GoSourceURL. Versions are only listed once the source cache exists.
+type SourceProvider struct{}
+
+func (SourceProvider) Name() string { return "source" }
+
+func (SourceProvider) Source() bool { return true }
+
+func (SourceProvider) List(ctx context.Context, m *Manager) ([]*GoVersion, error) {
+	if !m.hasSrcCache() {
+		return nil, nil
+	}
+	return m.AvailableSourceContext(ctx)
+}
+
+// Fetch always fails because SourceProvider builds versions rather than
+// fetching archives.
+func (SourceProvider) Fetch(ctx context.Context, m *Manager, version *GoVersion) (string, error) {
+	return "", fmt.Errorf("source provider has no archive of version %v: %w", version, errors.ErrUnsupported)
+}
+
+func (SourceProvider) build(ctx context.Context, m *Manager, version *GoVersion) (string, error) {
+	var dir string
+	err := m.withBuildLog(ctx, version, func(ctx context.Context) error {
+		var err error
+		dir, err = m.installSrc(ctx, version)
+		return err
+	})
+	return dir, err
+}
+
+// ModuleProxyProvider installs binary releases from the golang.org/toolchain
+// module on the module proxies given by the Manager's GOPROXY.
+type ModuleProxyProvider struct{}
+
+func (ModuleProxyProvider) Name() string { return "goproxy" }
+
+func (ModuleProxyProvider) Source() bool { return false }
+
+func (ModuleProxyProvider) List(ctx context.Context, m *Manager) ([]*GoVersion, error) {
+	return m.availableModuleProxy(ctx)
+}
+
+func (ModuleProxyProvider) Fetch(ctx context.Context, m *Manager, version *GoVersion) (string, error) {
+	return m.fetchModuleProxy(ctx, version)
+}
+
+// DirProvider installs binary distribution archives from a local directory.
+// Archives must use the go.dev file names (e.g. go1.22.5.linux-amd64.tar.gz).
+// If an archive has a .sha256 file next to it the archive is verified against
+// the hash it contains.
+type DirProvider struct {
+	Dir string
+}
+
+func (p DirProvider) Name() string { return "dir:" + p.Dir }
+
+func (DirProvider) Source() bool { return false }
+
+func (p DirProvider) List(_ context.Context, m *Manager) ([]*GoVersion, error) {
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := fmt.Sprintf(".%v-%v", m.GOOS, m.GOARCH)
+	var versions []*GoVersion
+	for _, e := range entries {
+		name := e.Name()
+		for _, ext := range []string{".tar.gz", ".zip"} {
+			if !strings.HasPrefix(name, "go") || !strings.HasSuffix(name, suffix+ext) {
+				continue
+			}
+			ver, err := ParseVersion(strings.TrimSuffix(name[2:], suffix+ext))
+			if err != nil {
+				continue
+			}
+			versions = append(versions, ver)
+		}
+	}
+
+	sortVersions(versions)
+	return versions, nil
+}
+
+func (p DirProvider) Fetch(_ context.Context, m *Manager, version *GoVersion) (string, error) {
+	for _, ext := range []string{".tar.gz", ".zip"} {
+		path := filepath.Join(p.Dir, fmt.Sprintf("go%v.%v-%v%v", version, m.GOOS, m.GOARCH, ext))
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+
+		sum, err := os.ReadFile(path + ".sha256")
+		switch {
+		case err == nil:
+			fields := strings.Fields(string(sum))
+			if len(fields) == 0 {
+				return "", fmt.Errorf("empty checksum file %v.sha256", path)
+			}
+			if err := common.VerifyFile(path, 0, fields[0]); err != nil {
+				return "", err
+			}
+		case os.IsNotExist(err):
+			m.Logger.WithField("file", path).Debug("No checksum file found, archive not verified.")
+		default:
+			return "", err
+		}
+
+		return path, nil
+	}
+	return "", fmt.Errorf("version %v not found in %v: %w", version, p.Dir, common.ErrNotFound)
+}
@@ -0,0 +1,79 @@
+package gvm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// emptyProvider is a Provider that has no versions.
+type emptyProvider struct {
+	fetches int
+}
+
+func (p *emptyProvider) Name() string { return "empty" }
+
+func (p *emptyProvider) Source() bool { return false }
+
+func (p *emptyProvider) List(ctx context.Context, m *Manager) ([]*GoVersion, error) { return nil, nil }
+
+func (p *emptyProvider) Fetch(ctx context.Context, m *Manager, version *GoVersion) (string, error) {
+	p.fetches++
+	return "", common.ErrNotFound
+}
+
+func TestProviderFallback(t *testing.T) {
+	dir := t.TempDir()
+	sum := writeTestArchive(t, filepath.Join(dir, "go1.22.5.linux-amd64.tar.gz"), "1.22.5")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go1.22.5.linux-amd64.tar.gz.sha256"), []byte(sum+"\n"), 0o644))
+	writeTestArchive(t, filepath.Join(dir, "go1.21.0.linux-amd64.tar.gz"), "1.21.0")
+	writeTestArchive(t, filepath.Join(dir, "go1.22.5.darwin-arm64.tar.gz"), "1.22.5")
+
+	empty := &emptyProvider{}
+	m := newTestManager(t, "")
+	m.Providers = []Provider{empty, DirProvider{Dir: dir}}
+
+	available, err := m.Available()
+	require.NoError(t, err)
+	require.Len(t, available, 2)
+	assert.Equal(t, "1.21.0", available[0].Version.String())
+	assert.Equal(t, "1.22.5", available[1].Version.String())
+	assert.True(t, available[1].Binary)
+	assert.False(t, available[1].Source)
+
+	ver, err := m.ResolveVersion("stable")
+	require.NoError(t, err)
+	assert.Equal(t, "1.22.5", ver.String())
+
+	goroot, err := m.Install(ver)
+	require.NoError(t, err)
+	assert.Equal(t, 1, empty.fetches)
+	assert.FileExists(t, filepath.Join(goroot, "bin", "go"))
+
+	_, err = m.Install(MustParseVersion("1.20.0"))
+	assert.ErrorIs(t, err, common.ErrNotFound)
+
+	// Build only uses providers that build from source.
+	_, err = m.Build(MustParseVersion("1.21.0"))
+	assert.Error(t, err)
+	assert.Equal(t, 2, empty.fetches)
+}
+
+func TestDirProviderChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "go1.22.5.linux-amd64.tar.gz")
+	writeTestArchive(t, archive, "1.22.5")
+	require.NoError(t, os.WriteFile(archive+".sha256", []byte("00  go1.22.5.linux-amd64.tar.gz\n"), 0o644))
+
+	m := newTestManager(t, "")
+	_, err := DirProvider{Dir: dir}.Fetch(context.Background(), m, MustParseVersion("1.22.5"))
+	var checksumErr *common.ChecksumError
+	require.ErrorAs(t, err, &checksumErr)
+	assert.NoDirExists(t, m.VersionGoROOT(MustParseVersion("1.22.5")))
+}
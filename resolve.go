@@ -0,0 +1,258 @@
+package gvm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Symbolic version specifiers understood by ResolveVersion.
+const (
+	SpecLatest    = "latest"    // Newest release, including pre-releases.
+	SpecStable    = "stable"    // Newest stable release.
+	SpecOldStable = "oldstable" // Newest stable release of the previous minor version.
+)
+
+var (
+	// minorLineRegexp matches specifiers like 1.22 that select the newest patch
+	// release of a minor version.
+	minorLineRegexp = regexp.MustCompile(`^(\d+)\.(\d+)$`)
+
+	// prereleaseLineRegexp matches specifiers like 1.23rc or 1.23beta that
+	// select the newest pre-release of a minor version.
+	prereleaseLineRegexp = regexp.MustCompile(`^(\d+)\.(\d+)(rc|beta)$`)
+)
+
+// candidate is a version that a version specifier can resolve to.
+type candidate struct {
+	version *GoVersion
+	stable  bool
+}
+
+// ResolveVersion resolves a version specifier to a concrete Go version. In
+// addition to exact versions (e.g. 1.22.5, 1.23rc2) and tip, the specifier
+// may be one of:
+//
+//	latest     newest release, including pre-releases
+//	stable     newest stable release
+//	oldstable  newest stable release of the previous minor version
+//	1.22       newest stable patch release of Go 1.22
+//	1.23rc     newest pre-release of Go 1.23 (1.23beta is also accepted)
+//
+// The specifier may also be a set of Constraints such as ">=1.21, <1.23" or
+// "~1.22". Constraints resolve to the newest installed version that satisfies
+// them, or to the newest available version if none is installed.
+//
+// The specifier may also be the name of a version built by BuildRef.
+//
+// Exact versions and names are returned without contacting the network. Symbolic
+// specifiers are resolved against the versions of the first provider that
+// lists any, so the source cache is used when the release index cannot be
+// fetched.
+func (m *Manager) ResolveVersion(spec string) (*GoVersion, error) {
+	return m.ResolveVersionContext(context.Background(), spec)
+}
+
+// ResolveVersionContext is like ResolveVersion but stops when ctx is done.
+func (m *Manager) ResolveVersionContext(ctx context.Context, spec string) (*GoVersion, error) {
+	if ver, err := NamedVersion(strings.TrimSpace(spec)); err == nil {
+		return ver, nil
+	}
+
+	spec = strings.TrimPrefix(strings.TrimSpace(spec), "go")
+	if spec == "" {
+		return nil, fmt.Errorf("no version specified")
+	}
+
+	if isConstraintSpec(spec) {
+		return m.resolveConstraints(ctx, spec)
+	}
+
+	if !isSymbolicSpec(spec) {
+		return ParseVersion(spec)
+	}
+
+	candidates, err := m.resolveCandidates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve version %q: %w", spec, err)
+	}
+
+	ver, err := selectVersion(spec, candidates)
+	if err != nil {
+		return nil, err
+	}
+	m.Logger.Debugf("Resolved version %q to %v", spec, ver)
+	return ver, nil
+}
+
+// isSymbolicSpec returns true if spec must be resolved against a list of
+// versions rather than parsed as an exact version.
+func isSymbolicSpec(spec string) bool {
+	switch spec {
+	case SpecLatest, SpecStable, SpecOldStable:
+		return true
+	}
+	return minorLineRegexp.MatchString(spec) || prereleaseLineRegexp.MatchString(spec)
+}
+
+// resolveCandidates returns the versions that symbolic specifiers are resolved
+// against. They are taken from the first provider that lists any versions.
+// Providers like the BinaryProvider know which releases are stable, for the
+// others the stability is derived from the version.
+func (m *Manager) resolveCandidates(ctx context.Context) ([]candidate, error) {
+	var firstErr error
+	for _, p := range m.providers() {
+		var candidates []candidate
+		var err error
+		if lister, ok := p.(candidateLister); ok {
+			candidates, err = lister.candidates(ctx, m)
+		} else {
+			var versions []*GoVersion
+			versions, err = p.List(ctx, m)
+			for _, ver := range versions {
+				if !ver.isRelease() {
+					continue
+				}
+				candidates = append(candidates, candidate{version: ver, stable: ver.Stable()})
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			m.Logger.WithError(err).WithField("provider", p.Name()).Info("Failed to list versions for resolving.")
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if len(candidates) > 0 {
+			return candidates, nil
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, nil
+}
+
+// releaseCandidates returns the releases in the release index that have an
+// archive for the Manager's GOOS and GOARCH.
+func (m *Manager) releaseCandidates(ctx context.Context) ([]candidate, error) {
+	releases, err := m.fetchGoReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]candidate, 0, len(releases))
+	for _, release := range releases {
+		if release.findArchiveFile(m.GOOS, m.GOARCH) == nil {
+			continue
+		}
+		ver, err := ParseVersion(strings.TrimPrefix(release.Version, "go"))
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, candidate{version: ver, stable: release.Stable})
+	}
+	return candidates, nil
+}
+
+// selectVersion returns the newest candidate matching the symbolic spec.
+func selectVersion(spec string, candidates []candidate) (*GoVersion, error) {
+	var match func(c candidate) bool
+
+	switch spec {
+	case SpecLatest:
+		match = func(candidate) bool { return true }
+	case SpecStable:
+		match = func(c candidate) bool { return c.stable }
+	case SpecOldStable:
+		stable, err := selectVersion(SpecStable, candidates)
+		if err != nil {
+			return nil, err
+		}
+		major, minor := stable.segments()
+		match = func(c candidate) bool {
+			cMajor, cMinor := c.version.segments()
+			return c.stable && (cMajor < major || (cMajor == major && cMinor < minor))
+		}
+	default:
+		if m := minorLineRegexp.FindStringSubmatch(spec); m != nil {
+			major, minor := atoi(m[1]), atoi(m[2])
+			match = func(c candidate) bool {
+				return c.stable && c.version.inMinorLine(major, minor)
+			}
+		} else if m := prereleaseLineRegexp.FindStringSubmatch(spec); m != nil {
+			major, minor, kind := atoi(m[1]), atoi(m[2]), m[3]
+			match = func(c candidate) bool {
+				return c.version.Prerelease() && c.version.inMinorLine(major, minor) &&
+					strings.HasPrefix(c.version.version.Prerelease(), kind)
+			}
+		} else {
+			return nil, fmt.Errorf("invalid version specifier %q", spec)
+		}
+	}
+
+	newest := newestMatching(candidates, match)
+	if newest == nil {
+		return nil, fmt.Errorf("no version matching %q found", spec)
+	}
+	return newest, nil
+}
+
+// resolveConstraints returns the newest installed version satisfying the
+// constraints, or the newest available version if none is installed.
+func (m *Manager) resolveConstraints(ctx context.Context, spec string) (*GoVersion, error) {
+	constraints, err := ParseConstraints(spec)
+	if err != nil {
+		return nil, err
+	}
+	match := func(c candidate) bool { return constraints.Check(c.version) }
+
+	installed, err := m.InstalledContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	installedCandidates := make([]candidate, 0, len(installed))
+	for _, ver := range installed {
+		installedCandidates = append(installedCandidates, candidate{version: ver, stable: ver.Stable()})
+	}
+	if ver := newestMatching(installedCandidates, match); ver != nil {
+		m.Logger.Debugf("Resolved version %q to installed version %v", spec, ver)
+		return ver, nil
+	}
+
+	candidates, err := m.resolveCandidates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve version %q: %w", spec, err)
+	}
+	ver := newestMatching(candidates, match)
+	if ver == nil {
+		return nil, fmt.Errorf("no version matching %q found", spec)
+	}
+	m.Logger.Debugf("Resolved version %q to %v", spec, ver)
+	return ver, nil
+}
+
+// newestMatching returns the newest candidate accepted by match. It returns
+// nil if there are no matches.
+func newestMatching(candidates []candidate, match func(candidate) bool) *GoVersion {
+	var newest *GoVersion
+	for _, c := range candidates {
+		if !c.version.isRelease() || !match(c) {
+			continue
+		}
+		if newest == nil || newest.LessThan(c.version) {
+			newest = c.version
+		}
+	}
+	return newest
+}
+
+// atoi converts a string of digits that was validated by a regular expression.
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
@@ -0,0 +1,52 @@
+package gvm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectVersion(t *testing.T) {
+	var candidates []candidate
+	for _, v := range []string{
+		"1.20", "1.20.14", "1.21rc2", "1.21.0", "1.21.13",
+		"1.22.0", "1.22.5", "1.23rc1", "1.23rc2", "1.23beta1",
+	} {
+		ver := MustParseVersion(v)
+		candidates = append(candidates, candidate{version: ver, stable: ver.Stable()})
+	}
+
+	cases := []struct {
+		Spec     string
+		Expected string
+	}{
+		{Spec: SpecLatest, Expected: "1.23rc2"},
+		{Spec: SpecStable, Expected: "1.22.5"},
+		{Spec: SpecOldStable, Expected: "1.21.13"},
+		{Spec: "1.20", Expected: "1.20.14"},
+		{Spec: "1.21", Expected: "1.21.13"},
+		{Spec: "1.23rc", Expected: "1.23rc2"},
+		{Spec: "1.23beta", Expected: "1.23beta1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Spec, func(t *testing.T) {
+			require.True(t, isSymbolicSpec(tc.Spec))
+			ver, err := selectVersion(tc.Spec, candidates)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, ver.String())
+		})
+	}
+
+	t.Run("no_match", func(t *testing.T) {
+		_, err := selectVersion("1.19", candidates)
+		assert.Error(t, err)
+	})
+
+	t.Run("exact", func(t *testing.T) {
+		for _, spec := range []string{"1.22.5", "1.23rc2", "tip"} {
+			assert.False(t, isSymbolicSpec(spec), spec)
+		}
+	})
+}
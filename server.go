@@ -0,0 +1,303 @@
+package gvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// CacheServer is an http.Handler that implements the parts of the go.dev/dl
+// API used by gvm. It serves the release index and archives from the
+// Manager's GoStorageHome. Archives are fetched on first use, verified
+// against the release index, and cached on disk so that other gvm clients can
+// point their GoStorageHome at the server.
+type CacheServer struct {
+	m        *Manager
+	dir      string
+	maxBytes int64
+
+	fetches singleflight.Group // Deduplicates concurrent fetches of an archive.
+
+	evictMu sync.Mutex     // Serializes cache evictions with opening archives.
+	inUse   map[string]int // Number of open handles of each archive being served.
+}
+
+// NewCacheServer returns a CacheServer that caches archives in dir. If dir is
+// empty a directory under the gvm cache is used. The total size of cached
+// archives is kept below maxBytes by evicting the least recently used
+// archives. A maxBytes <= 0 disables the limit.
+func (m *Manager) NewCacheServer(dir string, maxBytes int64) (*CacheServer, error) {
+	if dir == "" {
+		dir = filepath.Join(m.cacheDir, "serve")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CacheServer{m: m, dir: dir, maxBytes: maxBytes, inUse: map[string]int{}}, nil
+}
+
+func (s *CacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := s.m.Logger.WithField("path", r.URL.Path)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == "/" {
+		if r.URL.Query().Get("mode") != "json" {
+			http.Error(w, "only mode=json is supported", http.StatusBadRequest)
+			return
+		}
+		s.serveIndex(r.Context(), w, r.URL.Query().Get("include") == "all")
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, release, err := s.archive(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, common.ErrNotFound):
+			http.NotFound(w, r)
+		default:
+			log.WithError(err).Warn("Failed to fetch archive.")
+			http.Error(w, "failed to fetch archive from upstream", http.StatusBadGateway)
+		}
+		return
+	}
+
+	defer release()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.WithError(err).Warn("Failed to stat archive.")
+		http.Error(w, "failed to read archive", http.StatusInternalServerError)
+		return
+	}
+
+	log.Debug("Serving archive.")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// serveIndex writes the release index. Like go.dev it only lists the current
+// stable releases unless all is true.
+func (s *CacheServer) serveIndex(ctx context.Context, w http.ResponseWriter, all bool) {
+	releases, err := s.m.fetchGoReleases(ctx)
+	if err != nil {
+		s.m.Logger.WithError(err).Warn("Failed to fetch release index.")
+		http.Error(w, "failed to fetch release index from upstream", http.StatusBadGateway)
+		return
+	}
+
+	if !all {
+		releases = currentReleases(releases)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(releases); err != nil {
+		s.m.Logger.WithError(err).Debug("Failed to write release index.")
+	}
+}
+
+// findFile returns the release index entry for the named file.
+func (s *CacheServer) findFile(ctx context.Context, name string) (*GoFile, error) {
+	releases, err := s.m.fetchGoReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		for j := range releases[i].Files {
+			if releases[i].Files[j].Filename == name {
+				return &releases[i].Files[j], nil
+			}
+		}
+	}
+	return nil, common.ErrNotFound
+}
+
+// archive opens the cached copy of the named archive, fetching it from
+// upstream if needed. The archive is not evicted until release is called,
+// which also closes the file.
+func (s *CacheServer) archive(ctx context.Context, name string) (f *os.File, release func(), err error) {
+	file, err := s.findFile(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := filepath.Join(s.dir, name)
+	// A concurrent fetch of another archive may evict the archive between
+	// fetching and opening it, so try a few times.
+	for attempt := 1; ; attempt++ {
+		f, release, err := s.open(path)
+		if err == nil {
+			// Record the access for least recently used eviction.
+			now := time.Now()
+			_ = os.Chtimes(path, now, now)
+			return f, release, nil
+		}
+		if !os.IsNotExist(err) || attempt == 3 {
+			return nil, nil, err
+		}
+
+		// The fetch is shared by all requests for the archive so it is not
+		// canceled when the client that started it goes away.
+		_, err, _ = s.fetches.Do(name, func() (interface{}, error) {
+			return nil, s.fetch(context.WithoutCancel(ctx), file, path)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// open opens the cached archive at path and marks it as in use so that evict
+// does not remove it while it is served.
+func (s *CacheServer) open(path string) (*os.File, func(), error) {
+	s.evictMu.Lock()
+	defer s.evictMu.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.inUse[path]++
+
+	release := func() {
+		f.Close()
+
+		s.evictMu.Lock()
+		defer s.evictMu.Unlock()
+		if s.inUse[path]--; s.inUse[path] == 0 {
+			delete(s.inUse, path)
+		}
+	}
+	return f, release, nil
+}
+
+// fetch downloads file from upstream, verifies it, and stores it at path.
+func (s *CacheServer) fetch(ctx context.Context, file *GoFile, path string) error {
+	tmp, err := os.MkdirTemp(s.dir, ".fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	s.m.Logger.WithField("file", file.Filename).Info("Fetching archive from upstream.")
+	downloaded, err := s.m.downloadArchive(ctx, file, tmp)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(downloaded, path); err != nil {
+		return err
+	}
+
+	s.evict(path)
+	return nil
+}
+
+// evict removes the least recently used archives until the cache is below its
+// size limit. The archive at keep and archives that are being served are never
+// removed.
+func (s *CacheServer) evict(keep string) {
+	if s.maxBytes <= 0 {
+		return
+	}
+
+	s.evictMu.Lock()
+	defer s.evictMu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		s.m.Logger.WithError(err).Warn("Failed to list archive cache.")
+		return
+	}
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []cachedFile
+	var total int64
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cachedFile{
+			path:    filepath.Join(s.dir, e.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= s.maxBytes {
+			break
+		}
+		if f.path == keep || s.inUse[f.path] > 0 {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			s.m.Logger.WithError(err).Warnf("Failed to evict %v from archive cache.", f.path)
+			continue
+		}
+		s.m.Logger.WithField("file", f.path).Debug("Evicted archive from cache.")
+		total -= f.size
+	}
+}
+
+// currentReleases returns the newest stable release of the two newest minor
+// versions, matching the go.dev response without include=all.
+func currentReleases(releases []GoRelease) []GoRelease {
+	sorted := make([]GoRelease, len(releases))
+	copy(sorted, releases)
+	sortReleases(sorted)
+
+	var current []GoRelease
+	seen := map[string]bool{}
+	for _, release := range sorted {
+		if !release.Stable {
+			continue
+		}
+		ver, err := ParseVersion(strings.TrimPrefix(release.Version, "go"))
+		if err != nil {
+			continue
+		}
+		major, minor := ver.segments()
+		line := fmt.Sprintf("%d.%d", major, minor)
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		current = append(current, release)
+		if len(current) == 2 {
+			break
+		}
+	}
+	return current
+}
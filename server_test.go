@@ -0,0 +1,68 @@
+package gvm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheServer(t *testing.T) {
+	upstream := t.TempDir()
+	writeTestMirror(t, upstream, "1.22.4", "1.22.5")
+
+	cacheDir := t.TempDir()
+	handler, err := newTestManager(t, upstream).NewCacheServer(cacheDir, 1)
+	require.NoError(t, err)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := newTestManager(t, srv.URL)
+	versions, err := client.AvailableBinaries()
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	_, err = client.Install(MustParseVersion("1.22.4"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(cacheDir, "go1.22.4.linux-amd64.tar.gz"))
+
+	// The cache size limit evicts the least recently used archive.
+	_, err = client.Install(MustParseVersion("1.22.5"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(cacheDir, "go1.22.5.linux-amd64.tar.gz"))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "go1.22.4.linux-amd64.tar.gz"))
+
+	// Unknown files are not proxied.
+	resp, err := http.Get(srv.URL + "/go1.23.0.linux-amd64.tar.gz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCacheServerKeepsServedArchives(t *testing.T) {
+	upstream := t.TempDir()
+	writeTestMirror(t, upstream, "1.22.4", "1.22.5")
+
+	cacheDir := t.TempDir()
+	s, err := newTestManager(t, upstream).NewCacheServer(cacheDir, 1)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	f, release, err := s.archive(ctx, "go1.22.4.linux-amd64.tar.gz")
+	require.NoError(t, err)
+
+	// The archive is being served so fetching another one does not evict it.
+	_, release2, err := s.archive(ctx, "go1.22.5.linux-amd64.tar.gz")
+	require.NoError(t, err)
+	release2()
+	assert.FileExists(t, filepath.Join(cacheDir, "go1.22.4.linux-amd64.tar.gz"))
+	_, err = io.Copy(io.Discard, f)
+	assert.NoError(t, err)
+	release()
+	assert.Empty(t, s.inUse)
+}
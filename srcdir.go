@@ -0,0 +1,161 @@
+package gvm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BuildDir builds the Go source tree at dir, such as a developer's checkout
+// of the Go repository, and installs it as the named version. Uncommitted
+// changes are included. The tree is copied before building so that the
+// checkout is not modified. A version with the same name is replaced.
+func (m *Manager) BuildDir(dir, name string) (*GoVersion, string, error) {
+	return m.BuildDirContext(context.Background(), dir, name)
+}
+
+// BuildDirContext is like BuildDir but stops copying and building when ctx is
+// done. Partial builds are removed.
+func (m *Manager) BuildDirContext(ctx context.Context, dir, name string) (*GoVersion, string, error) {
+	version, err := NamedVersion(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "src", "make.bash")); err != nil {
+		return nil, "", fmt.Errorf("%v is not a Go source tree: %w", dir, err)
+	}
+
+	info := BuildInfo{Dir: dir}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		// The commit is informational only, the tree may not be a clean
+		// checkout of it.
+		info.Commit, _ = gitRevParse(ctx, m.Logger, dir, "HEAD^{commit}")
+	}
+
+	goroot, err := shareFlight(ctx, &m.flights, "build:"+m.versionDir(version), func(ctx context.Context) (string, error) {
+		var goroot string
+		err := m.withLock(ctx, m.versionLock(version), func() error {
+			return m.withBuildLog(ctx, version, func(ctx context.Context) error {
+				bootstrap := func(srcDir string) (string, error) {
+					return m.bootstrapGOROOT(ctx, version, srcDir)
+				}
+
+				var err error
+				goroot, err = m.installBuild(ctx, version, func(buildDir string) error {
+					return buildGoDir(ctx, m.logger(ctx), buildDir, dir, version, bootstrap, m.buildProgress)
+				})
+				if err != nil {
+					return err
+				}
+				info.Built = time.Now().UTC()
+				return writeJSONFile(filepath.Join(goroot, buildInfoFile), info)
+			})
+		})
+		return goroot, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return version, goroot, nil
+}
+
+// buildGoDir builds a copy of the Go source tree at srcDir in buildDir/go.
+// bootstrap and step are used like in buildGo.
+func buildGoDir(ctx context.Context, log logrus.FieldLogger, buildDir, srcDir string, version *GoVersion, bootstrap func(srcDir string) (string, error), step func(string)) error {
+	log.Println("copy source tree:", srcDir)
+	step("Copying " + srcDir)
+	tmp := filepath.Join(buildDir, "go")
+	if err := copySourceTree(ctx, srcDir, tmp); err != nil {
+		return err
+	}
+
+	bootstrapGOROOT, err := bootstrap(tmp)
+	if err != nil {
+		return err
+	}
+
+	// Without .git make.bash needs a VERSION file to name the toolchain.
+	versionFile := filepath.Join(tmp, "VERSION")
+	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
+		if err := os.WriteFile(versionFile, []byte("devel "+version.String()), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return makeGo(ctx, log, tmp, bootstrapGOROOT, step)
+}
+
+// copySourceTree copies the Go source tree at src to dst. The git repository
+// and the bin and pkg directories of a previous in-place build are skipped.
+func copySourceTree(ctx context.Context, src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case rel == ".git" && !d.IsDir():
+			// .git is a file in worktrees and submodules.
+			return nil
+		case rel == ".git" || rel == "bin" || rel == "pkg":
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		to := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(to, info.Mode().Perm()|0o700)
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, to)
+		case d.Type().IsRegular():
+			return copyRegularFile(path, to, info.Mode().Perm())
+		default:
+			// Skip sockets, pipes, and devices.
+			return nil
+		}
+	})
+}
+
+func copyRegularFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %v: %w", src, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package gvm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDir(t *testing.T) {
+	// A fake Go checkout with an earlier in-place build whose make.bash records
+	// the VERSION and a file with local changes as bin/go.
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "src", "make.bash"), "mkdir -p ../bin && cat ../VERSION ../HACK > ../bin/go\n")
+	writeFile(t, filepath.Join(src, "src", "internal", "goversion", "goversion.go"), "package goversion\n\nconst Version = 24\n")
+	writeFile(t, filepath.Join(src, "HACK"), "\nfirst")
+	writeFile(t, filepath.Join(src, "bin", "stale"), "stale")
+	require.NoError(t, os.Symlink("HACK", filepath.Join(src, "HACK.link")))
+
+	m := newTestManager(t, "")
+	m.BootstrapGOROOT = t.TempDir()
+
+	ver, dir, err := m.BuildDir(src, "dev-scheduler")
+	require.NoError(t, err)
+	assert.Equal(t, "dev-scheduler", ver.String())
+	assert.Equal(t, m.VersionGoROOT(ver), dir)
+	assert.Equal(t, "devel dev-scheduler\nfirst", readFile(t, filepath.Join(dir, "bin", "go")))
+	assert.NoFileExists(t, filepath.Join(dir, "bin", "stale"))
+	target, err := os.Readlink(filepath.Join(dir, "HACK.link"))
+	require.NoError(t, err)
+	assert.Equal(t, "HACK", target)
+
+	// The checkout is not modified.
+	assert.NoFileExists(t, filepath.Join(src, "VERSION"))
+	assert.NoFileExists(t, filepath.Join(src, "bin", "go"))
+
+	info, err := m.ReadBuildInfo(ver)
+	require.NoError(t, err)
+	assert.Equal(t, src, info.Dir)
+	assert.Empty(t, info.Commit)
+
+	_, err = m.LatestBuildLog(ver)
+	assert.NoError(t, err)
+
+	installed, err := m.Installed()
+	require.NoError(t, err)
+	assert.Equal(t, "dev-scheduler", joinVersions(installed))
+
+	// Building again picks up changes and replaces the version.
+	writeFile(t, filepath.Join(src, "HACK"), "\nsecond")
+	_, dir, err = m.BuildDir(src, "dev-scheduler")
+	require.NoError(t, err)
+	assert.Equal(t, "devel dev-scheduler\nsecond", readFile(t, filepath.Join(dir, "bin", "go")))
+
+	_, _, err = m.BuildDir(t.TempDir(), "dev")
+	assert.ErrorContains(t, err, "is not a Go source tree")
+	_, _, err = m.BuildDir(src, "1.24.0")
+	assert.ErrorContains(t, err, "invalid version name")
+}
@@ -0,0 +1,209 @@
+package gvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// buildInfoFile is the file in the GOROOT of a version built by BuildRef that
+// records what it was built from.
+const buildInfoFile = "gvm-build.json"
+
+// BuildInfo records the git revision or source tree that a named version was
+// built from.
+type BuildInfo struct {
+	Ref        string    `json:"ref,omitempty"`        // Ref given to BuildRef.
+	Commit     string    `json:"commit,omitempty"`     // Commit the ref resolved to, or HEAD of the source tree.
+	Repository string    `json:"repository,omitempty"` // Repository the commit was fetched from.
+	Dir        string    `json:"dir,omitempty"`        // Source tree built by BuildDir.
+	Built      time.Time `json:"built"`                // Time the build finished.
+}
+
+// BuildRef builds a git ref of the Go repository and installs it as a named
+// version. The ref may be a branch (e.g. release-branch.go1.23), a tag, a
+// commit hash, or a Gerrit change ref (e.g. refs/changes/45/612345/2). If
+// name is empty the version is named ref-<commit>, using the first 12
+// characters of the commit hash. A version with the same name is rebuilt
+// unless it was built from the same commit. The commit is recorded and can
+// be read with ReadBuildInfo.
+func (m *Manager) BuildRef(ref, name string) (*GoVersion, string, error) {
+	return m.BuildRefContext(context.Background(), ref, name)
+}
+
+// BuildRefContext is like BuildRef but stops the git commands and the build
+// when ctx is done. Partial builds are removed.
+func (m *Manager) BuildRefContext(ctx context.Context, ref, name string) (*GoVersion, string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n") {
+		return nil, "", fmt.Errorf("invalid git ref %q", ref)
+	}
+	if name != "" {
+		if _, err := NamedVersion(name); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// The build log is opened before the git commands that resolve the ref.
+	// Unless a name is given it is renamed once the commit is known.
+	logName := name
+	if logName == "" {
+		logName = "ref-" + sanitizeRef(ref)
+	}
+	logVersion, err := NamedVersion(logName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var version *GoVersion
+	var dir string
+	err = m.withBuildLog(ctx, logVersion, func(ctx context.Context) error {
+		if err := m.ensureSrcCache(ctx); err != nil {
+			return err
+		}
+		commit, err := m.resolveRef(ctx, ref)
+		if err != nil {
+			return err
+		}
+		versionName := name
+		if versionName == "" {
+			versionName = "ref-" + commit[:12]
+		}
+		if version, err = NamedVersion(versionName); err != nil {
+			return err
+		}
+		m.renameBuildLog(ctx, version)
+
+		dir, err = shareFlight(ctx, &m.flights, "build:"+m.versionDir(version), func(ctx context.Context) (string, error) {
+			var dir string
+			err := m.withLock(ctx, m.versionLock(version), func() error {
+				if info, err := m.ReadBuildInfoContext(ctx, version); err == nil && info.Commit == commit {
+					m.logger(ctx).Debugf("Version %v is already built from %v.", version, commit)
+					discardBuildLog(ctx)
+					dir = m.VersionGoROOT(version)
+					return nil
+				}
+
+				m.logger(ctx).Infof("Building %v at commit %v.", ref, commit)
+				var err error
+				if dir, err = m.buildSrc(ctx, version, commit); err != nil {
+					return err
+				}
+				return writeJSONFile(filepath.Join(dir, buildInfoFile), BuildInfo{
+					Ref:        ref,
+					Commit:     commit,
+					Repository: m.GoSourceURL,
+					Built:      time.Now().UTC(),
+				})
+			})
+			return dir, err
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return version, dir, nil
+}
+
+// sanitizeRef replaces the characters of a git ref that are not allowed in
+// version names with '_'.
+func sanitizeRef(ref string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("._+-", r):
+			return r
+		}
+		return '_'
+	}, ref)
+}
+
+// ReadBuildInfo returns the git revision or source tree that a version built
+// by BuildRef or BuildDir was built from.
+func (m *Manager) ReadBuildInfo(version *GoVersion) (*BuildInfo, error) {
+	return m.ReadBuildInfoContext(context.Background(), version)
+}
+
+// ReadBuildInfoContext is like ReadBuildInfo but returns ctx.Err() if ctx is
+// done.
+func (m *Manager) ReadBuildInfoContext(ctx context.Context, version *GoVersion) (*BuildInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	info := &BuildInfo{}
+	if err := readJSONFile(filepath.Join(m.VersionGoROOT(version), buildInfoFile), info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// resolveRef returns the commit hash of the ref. Unless the Manager is
+// offline the ref is fetched from GoSourceURL first so that branches are up
+// to date and refs that are not fetched by default, like Gerrit changes, are
+// available.
+func (m *Manager) resolveRef(ctx context.Context, ref string) (string, error) {
+	var commit string
+	err := m.withLock(ctx, srcCacheLock, func() error {
+		log := m.logger(ctx)
+		repo := m.srcCacheDir()
+
+		if !m.Offline {
+			err := gitFetch(ctx, log, repo, ref)
+			if err == nil {
+				if commit, err = gitRevParse(ctx, log, repo, "FETCH_HEAD^{commit}"); err != nil {
+					return err
+				}
+				// Keep a ref to the commit so that it is not garbage
+				// collected from the source cache.
+				return gitUpdateRef(ctx, log, repo, "refs/gvm/"+commit, commit)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Servers may not allow fetching commits by hash.
+			log.WithError(err).Debugf("Failed to fetch %v, resolving it in the source cache.", ref)
+		}
+
+		if c, err := gitRevParse(ctx, log, repo, ref+"^{commit}"); err == nil {
+			commit = c
+			return nil
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if m.Offline {
+			return fmt.Errorf("unknown git ref %q: %w", ref, ErrOffline)
+		}
+		return fmt.Errorf("unknown git ref %q: %w", ref, common.ErrNotFound)
+	})
+	return commit, err
+}
+
+func gitFetch(ctx context.Context, logger logrus.FieldLogger, path, ref string) error {
+	logger.Println("git fetch:")
+	return makeCommand("git", "fetch", "origin", ref).WithDir(path).WithLogger(logger).Exec(ctx)
+}
+
+func gitRevParse(ctx context.Context, logger logrus.FieldLogger, path, rev string) (string, error) {
+	var commit string
+	logger.Println("git rev-parse:")
+	cmd := makeCommand("git", "rev-parse", "--verify", "--quiet", rev)
+	cmd.Stdout = func(l string) { commit = strings.TrimSpace(l) }
+	if err := cmd.WithDir(path).WithLogger(logger).Exec(ctx); err != nil {
+		return "", err
+	}
+	if len(commit) < 12 {
+		return "", errors.New("git rev-parse returned no commit")
+	}
+	return commit, nil
+}
+
+func gitUpdateRef(ctx context.Context, logger logrus.FieldLogger, path, ref, commit string) error {
+	logger.Println("git update-ref:")
+	return makeCommand("git", "update-ref", ref, commit).WithDir(path).WithLogger(logger).Exec(ctx)
+}
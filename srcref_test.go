@@ -0,0 +1,222 @@
+//go:build unix
+
+package gvm
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedVersion(t *testing.T) {
+	for _, name := range []string{"ref-0123456789ab", "my-fix", "cl612345.2", "gofix"} {
+		ver, err := NamedVersion(name)
+		if assert.NoError(t, err, name) {
+			assert.True(t, ver.IsNamed())
+			assert.False(t, ver.Stable())
+			assert.Equal(t, name, ver.String())
+		}
+	}
+	for _, name := range []string{"", "tip", "stable", "latest", "1.22.5", "go1.22", "v1.2", "-x", "a/b", "1fix"} {
+		_, err := NamedVersion(name)
+		assert.Error(t, err, name)
+	}
+
+	versions := []*GoVersion{MustParseVersion("tip"), mustNamedVersion(t, "b"), MustParseVersion("1.22.5"), mustNamedVersion(t, "a")}
+	sortVersions(versions)
+	assert.Equal(t, "1.22.5 a b tip", joinVersions(versions))
+}
+
+func TestBuildRef(t *testing.T) {
+	upstream := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = upstream
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=gvm", "GIT_AUTHOR_EMAIL=gvm@example.com",
+			"GIT_COMMITTER_NAME=gvm", "GIT_COMMITTER_EMAIL=gvm@example.com")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(msg string) string {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(upstream, "CHANGES"), []byte(msg), 0o644))
+		git("add", "-A")
+		git("commit", "-q", "-m", msg)
+		return git("rev-parse", "HEAD")
+	}
+
+	// A fake Go source tree whose make.bash records the commit it was built
+	// from as bin/go.
+	git("init", "-q", "-b", "master")
+	writeFile(t, filepath.Join(upstream, "src", "make.bash"), "git rev-parse HEAD > ../bin/go\n")
+	writeFile(t, filepath.Join(upstream, "bin", ".keep"), "")
+	writeFile(t, filepath.Join(upstream, "src", "internal", "goversion", "goversion.go"), "package goversion\n\nconst Version = 24\n")
+	commit("initial")
+	git("checkout", "-q", "-b", "release-branch.go1.23")
+	branch := commit("release branch fix")
+	git("checkout", "-q", "master")
+	change := commit("pending change")
+	git("update-ref", "refs/changes/45/612345/2", change)
+	git("reset", "-q", "--hard", "HEAD~1")
+
+	// Serve partial clones like the Go repository does.
+	git("config", "uploadpack.allowFilter", "true")
+
+	m := newTestManager(t, "")
+	m.GoSourceURL = "file://" + upstream
+	m.BootstrapGOROOT = t.TempDir()
+	// The source cache of older gvm versions is replaced.
+	writeFile(t, filepath.Join(m.legacySrcCacheDir(), "README"), "")
+
+	ver, dir, err := m.BuildRef("release-branch.go1.23", "")
+	require.NoError(t, err)
+	assert.Equal(t, "ref-"+branch[:12], ver.String())
+	assert.Equal(t, branch+"\n", readFile(t, filepath.Join(dir, "bin", "go")))
+	info, err := m.ReadBuildInfo(ver)
+	require.NoError(t, err)
+	assert.Equal(t, "release-branch.go1.23", info.Ref)
+	assert.Equal(t, branch, info.Commit)
+
+	// The build log includes the git commands that ran before the version
+	// name was known.
+	logs, err := m.BuildLogs(ver)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Contains(t, readFile(t, logs[0]), "git clone")
+	logs, err = m.BuildLogs(mustNamedVersion(t, "ref-release-branch.go1.23"))
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+
+	// The source cache is a bare partial clone and the build's worktree is
+	// detached from it.
+	assert.NoDirExists(t, m.legacySrcCacheDir())
+	assert.Equal(t, "blob:none", gitOutput(t, m.srcCacheDir(), "config", "remote.origin.partialclonefilter"))
+	assert.Equal(t, "true", gitOutput(t, m.srcCacheDir(), "rev-parse", "--is-bare-repository"))
+	assert.NoFileExists(t, filepath.Join(dir, ".git"))
+	assert.Len(t, strings.Split(gitOutput(t, m.srcCacheDir(), "worktree", "list"), "\n"), 1)
+
+	ver, dir, err = m.BuildRef("refs/changes/45/612345/2", "cl612345")
+	require.NoError(t, err)
+	assert.Equal(t, "cl612345", ver.String())
+	assert.Equal(t, change+"\n", readFile(t, filepath.Join(dir, "bin", "go")))
+
+	// Updating the source cache fetches new commits on branches.
+	head := commit("new commit")
+	require.NoError(t, m.UpdateCacheContext(context.Background()))
+	assert.Equal(t, head, gitOutput(t, m.srcCacheDir(), "rev-parse", "master"))
+
+	// Named versions are listed and resolved.
+	installed, err := m.Installed()
+	require.NoError(t, err)
+	assert.Equal(t, "cl612345 ref-"+branch[:12], joinVersions(installed))
+	resolved, err := m.ResolveVersion("cl612345")
+	require.NoError(t, err)
+	assert.Equal(t, ver, resolved)
+	goroot, err := m.Install(resolved)
+	require.NoError(t, err)
+	assert.Equal(t, dir, goroot)
+
+	// Building the same commit again reuses the build.
+	writeFile(t, filepath.Join(dir, "bin", "go"), "unchanged")
+	m.Offline = true
+	_, _, err = m.BuildRef(change[:8], "cl612345")
+	require.NoError(t, err)
+	assert.Equal(t, "unchanged", readFile(t, filepath.Join(dir, "bin", "go")))
+	logs, err = m.BuildLogs(ver)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1, "no log is kept when nothing is built")
+
+	_, _, err = m.BuildRef("no-such-branch", "")
+	assert.ErrorIs(t, err, ErrOffline)
+
+	// The files of the new commit were not fetched by the partial clone.
+	_, _, err = m.BuildRef("master", "")
+	assert.ErrorIs(t, err, ErrOffline)
+	assert.ErrorContains(t, err, "source cache is missing")
+	assert.Len(t, strings.Split(gitOutput(t, m.srcCacheDir(), "worktree", "list"), "\n"), 1)
+}
+
+func TestSrcCacheFromLegacyClone(t *testing.T) {
+	upstream := t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=gvm", "GIT_AUTHOR_EMAIL=gvm@example.com",
+			"GIT_COMMITTER_NAME=gvm", "GIT_COMMITTER_EMAIL=gvm@example.com")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	git(upstream, "init", "-q", "-b", "master")
+	writeFile(t, filepath.Join(upstream, "src", "make.bash"), "git rev-parse HEAD > ../bin/go\n")
+	writeFile(t, filepath.Join(upstream, "bin", ".keep"), "")
+	git(upstream, "add", "-A")
+	git(upstream, "commit", "-q", "-m", "initial")
+	git(upstream, "tag", "go1.4")
+	git(upstream, "checkout", "-q", "-b", "release-branch.go1.4")
+	git(upstream, "commit", "-q", "--allow-empty", "-m", "release branch fix")
+	branch := git(upstream, "rev-parse", "HEAD")
+
+	m := newTestManager(t, "")
+	m.GoSourceURL = "file://" + upstream
+	m.BootstrapGOROOT = t.TempDir()
+	m.Offline = true
+
+	// The full clone of older gvm versions.
+	legacy := m.legacySrcCacheDir()
+	require.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0o755))
+	git(filepath.Dir(legacy), "clone", "-q", m.GoSourceURL, legacy)
+
+	// The source cache is created offline from it.
+	ver, dir, err := m.BuildRef("release-branch.go1.4", "")
+	require.NoError(t, err)
+	assert.Equal(t, "ref-"+branch[:12], ver.String())
+	assert.Equal(t, branch+"\n", readFile(t, filepath.Join(dir, "bin", "go")))
+
+	assert.NoDirExists(t, legacy)
+	assert.Equal(t, "true", gitOutput(t, m.srcCacheDir(), "rev-parse", "--is-bare-repository"))
+	assert.Equal(t, m.GoSourceURL, gitOutput(t, m.srcCacheDir(), "config", "remote.origin.url"))
+	assert.Equal(t, "master\nrelease-branch.go1.4", gitOutput(t, m.srcCacheDir(), "for-each-ref", "--format=%(refname:short)", "refs/heads"))
+	assert.Equal(t, "go1.4", gitOutput(t, m.srcCacheDir(), "tag"))
+
+	// Once online, it fetches from GoSourceURL.
+	m.Offline = false
+	git(upstream, "commit", "-q", "--allow-empty", "-m", "new commit")
+	require.NoError(t, m.UpdateCacheContext(context.Background()))
+	assert.Equal(t, git(upstream, "rev-parse", "HEAD"), gitOutput(t, m.srcCacheDir(), "rev-parse", "release-branch.go1.4"))
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	return strings.TrimSpace(string(out))
+}
+
+func mustNamedVersion(t *testing.T, name string) *GoVersion {
+	t.Helper()
+	ver, err := NamedVersion(name)
+	require.NoError(t, err)
+	return ver
+}
+
+func joinVersions(versions []*GoVersion) string {
+	s := make([]string, 0, len(versions))
+	for _, v := range versions {
+		s = append(s, v.String())
+	}
+	return strings.Join(s, " ")
+}
@@ -1,6 +1,7 @@
 package gvm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -20,7 +21,15 @@ type srcCacheInfo struct {
 	Updated time.Time
 }
 
+// srcCacheDir returns the source cache. It is a bare, partial clone of
+// GoSourceURL that builds check out versions from using git worktrees.
 func (m *Manager) srcCacheDir() string {
+	return filepath.Join(m.cacheDir, "go.git")
+}
+
+// legacySrcCacheDir returns the full clone with a working tree that older
+// gvm versions used as the source cache.
+func (m *Manager) legacySrcCacheDir() string {
 	return filepath.Join(m.cacheDir, "go")
 }
 
@@ -30,30 +39,58 @@ func (m *Manager) hasSrcCache() bool {
 	return err == nil && exists
 }
 
-func (m *Manager) ensureSrcCache() error {
-	localGoSrc := m.srcCacheDir()
-	exists, err := existsDir(localGoSrc)
-	if err != nil {
-		return err
-	}
-	if exists {
+// srcCacheLock is the name of the lock for updating the source cache.
+const srcCacheLock = "source-cache"
+
+func (m *Manager) ensureSrcCache(ctx context.Context) error {
+	if m.hasSrcCache() {
 		return nil
 	}
 
-	return m.updateSrcCache()
+	return m.withLock(ctx, srcCacheLock, func() error {
+		// Another process may have created the cache while we waited.
+		if m.hasSrcCache() {
+			return nil
+		}
+		return m.updateSrcCacheLocked(ctx)
+	})
 }
 
-func (m *Manager) updateSrcCache() error {
-	localGoSrc := filepath.Join(m.cacheDir, "go")
+func (m *Manager) updateSrcCache(ctx context.Context) error {
+	return m.withLock(ctx, srcCacheLock, func() error {
+		return m.updateSrcCacheLocked(ctx)
+	})
+}
+
+// updateSrcCacheLocked clones or pulls the source cache. The caller must hold
+// the source cache lock. In offline mode the source cache can only be created
+// from the source cache of older gvm versions.
+func (m *Manager) updateSrcCacheLocked(ctx context.Context) error {
+	log := m.logger(ctx)
+	localGoSrc := m.srcCacheDir()
 	exists, err := existsDir(localGoSrc)
 	if err != nil {
 		return err
 	}
 
 	if !exists {
-		err = gitClone(m.Logger, localGoSrc, m.GoSourceURL, false)
+		seeded, err := m.seedSrcCache(ctx, log, localGoSrc)
+		if err != nil {
+			return err
+		}
+		if seeded && m.Offline {
+			return nil
+		}
+		exists = seeded
+	}
+	if m.Offline {
+		return fmt.Errorf("cannot update source cache: %w", ErrOffline)
+	}
+
+	if !exists {
+		err = m.cloneSrcCache(ctx, log, localGoSrc)
 	} else {
-		err = gitPull(m.Logger, localGoSrc)
+		err = gitFetchBranches(ctx, log, localGoSrc)
 	}
 	if err != nil {
 		return err
@@ -64,27 +101,125 @@ func (m *Manager) updateSrcCache() error {
 	})
 }
 
-func (m *Manager) installSrc(version *GoVersion) (string, error) {
-	log := m.Logger
+// cloneSrcCache creates the source cache as a bare clone that fetches all
+// branches and tags, but not other refs like Gerrit changes.
+func (m *Manager) cloneSrcCache(ctx context.Context, log logrus.FieldLogger, to string) error {
+	filter := m.SourceFilter
+	if filter == "none" {
+		filter = ""
+	}
+	return gitClone(ctx, log, to, m.GoSourceURL, true, filter)
+}
 
-	if err := m.ensureSrcCache(); err != nil {
-		return "", err
+// seedSrcCache creates the source cache from the full clone used by older gvm
+// versions, without network access, and removes the old clone. It returns
+// false if there is no old clone to seed from.
+func (m *Manager) seedSrcCache(ctx context.Context, log logrus.FieldLogger, to string) (bool, error) {
+	legacy := m.legacySrcCacheDir()
+	if legacy == to {
+		return false, nil
+	}
+	if exists, _ := existsDir(legacy); !exists {
+		return false, nil
 	}
 
-	tag := "master"
-	to := m.VersionGoROOT(version)
+	seeded := false
+	if isRepo, _ := existsDir(filepath.Join(legacy, ".git")); isRepo {
+		log.Println("seed source cache from old source cache:", legacy)
+		if err := gitCloneLegacy(ctx, log, to, legacy, m.GoSourceURL); err != nil {
+			log.WithError(err).Warn("Failed to seed source cache from old source cache.")
+		} else {
+			seeded = true
+		}
+	}
 
-	exists, err := existsDir(to)
-	if err != nil {
+	log.Println("remove old source cache:", legacy)
+	if err := os.RemoveAll(legacy); err != nil {
+		return seeded, err
+	}
+	return seeded, nil
+}
+
+// installSrc builds the release or tip from source. Callers run it with a
+// build log so that the output of the git and build commands is kept.
+func (m *Manager) installSrc(ctx context.Context, version *GoVersion) (string, error) {
+	if err := m.ensureSrcCache(ctx); err != nil {
 		return "", err
 	}
 
+	tag := "master"
 	if !version.IsTip() {
 		tag = fmt.Sprintf("go%v", version)
-		if err := m.ensureSrcVersionAvail(version); err != nil {
+		if err := m.ensureSrcVersionAvail(ctx, version); err != nil {
 			return "", err
 		}
 	}
+	return m.buildSrc(ctx, version, tag)
+}
+
+// buildSrc builds the tag (or any other git revision) of the source cache and
+// installs it as the version, replacing an existing installation.
+func (m *Manager) buildSrc(ctx context.Context, version *GoVersion, tag string) (string, error) {
+	if err := m.checkSrcObjects(ctx, tag); err != nil {
+		return "", err
+	}
+
+	bootstrap := func(srcDir string) (string, error) {
+		return m.bootstrapGOROOT(ctx, version, srcDir)
+	}
+	return m.installBuild(ctx, version, func(buildDir string) error {
+		return buildGo(ctx, m.logger(ctx), buildDir, m.srcCacheDir(), m.gitEnv(), version, tag, bootstrap, m.buildProgress)
+	})
+}
+
+// gitEnv returns the environment of git commands that read objects from the
+// source cache. In offline mode git must not fetch the objects missing from
+// the partial clone.
+func (m *Manager) gitEnv() []string {
+	if m.Offline {
+		return []string{"GIT_NO_LAZY_FETCH=1"}
+	}
+	return nil
+}
+
+// checkSrcObjects returns ErrOffline in offline mode if the source cache lacks
+// objects needed to check out rev. Partial clones fetch file contents on
+// demand, so only the revisions that were built before are available offline.
+func (m *Manager) checkSrcObjects(ctx context.Context, rev string) error {
+	if !m.Offline {
+		return nil
+	}
+
+	log := m.logger(ctx)
+	missing := 0
+	log.Println("git rev-list:")
+	cmd := makeCommand("git", "rev-list", "--objects", "--missing=print", "--no-walk", rev)
+	cmd.Env = m.gitEnv()
+	cmd.Stdout = func(line string) {
+		if strings.HasPrefix(line, "?") {
+			missing++
+		}
+	}
+	if err := cmd.WithDir(m.srcCacheDir()).WithLogger(log).Exec(ctx); err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("source cache is missing %d files of %v: %w", missing, rev, ErrOffline)
+	}
+	return nil
+}
+
+// installBuild calls build with a temporary directory in which it must build
+// a GOROOT named go, and installs the GOROOT as the version, replacing an
+// existing installation.
+func (m *Manager) installBuild(ctx context.Context, version *GoVersion, build func(buildDir string) error) (string, error) {
+	log := m.logger(ctx)
+
+	to := m.VersionGoROOT(version)
+	exists, err := existsDir(to)
+	if err != nil {
+		return "", err
+	}
 
 	godir := m.versionDir(version)
 
@@ -95,8 +230,7 @@ func (m *Manager) installSrc(version *GoVersion) (string, error) {
 	}
 	defer os.RemoveAll(tmpRoot)
 
-	err = buildGo(log, tmpRoot, m.srcCacheDir(), version, tag)
-	if err != nil {
+	if err := build(tmpRoot); err != nil {
 		return "", err
 	}
 
@@ -115,26 +249,37 @@ func (m *Manager) installSrc(version *GoVersion) (string, error) {
 	return to, nil
 }
 
-func buildGo(log logrus.FieldLogger, buildDir, repo string, version *GoVersion, tag string) error {
-	log.Println("copy cache")
-	tmp := filepath.Join(buildDir, "go")
-	if err := gitClone(log, tmp, repo, false); err != nil {
-		return err
-	}
+// buildGo builds the tag of the Go repository in buildDir/go. gitEnv is added
+// to the environment of git. bootstrap is called with the checked out source
+// tree and returns the GOROOT of the Go toolchain to build it with, or an
+// empty string for versions written in C. The build steps are passed to step.
+func buildGo(ctx context.Context, log logrus.FieldLogger, buildDir, repo string, gitEnv []string, version *GoVersion, tag string, bootstrap func(srcDir string) (string, error), step func(string)) error {
 	log.Println("checkout tag:", tag)
-	if err := gitCheckout(log, tmp, tag); err != nil {
+	step("Checking out " + tag)
+	tmp := filepath.Join(buildDir, "go")
+	if err := gitWorktreeAdd(ctx, log, repo, tmp, tag, gitEnv); err != nil {
 		return err
 	}
-
-	bootstrap := os.Getenv("GOROOT_BOOTSTRAP")
-	if bootstrap == "" {
-		bootstrap = os.Getenv("GOROOT")
-		if bootstrap == "" {
-			return errors.New("GOROOT or GOROOT_BOOTSTRAP must be set")
+	// The built GOROOT is moved out of the worktree. Detach it from the source
+	// cache, whether or not the build succeeds. make.bash records the version
+	// of builds from git in VERSION.cache, so .git is no longer needed.
+	defer func() {
+		if err := os.Remove(filepath.Join(tmp, ".git")); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Warn("Failed to remove worktree .git file.")
 		}
+		// Use a fresh context so that the worktree is cleaned up after a
+		// canceled build.
+		if err := gitWorktreePrune(context.Background(), log, repo); err != nil {
+			log.WithError(err).Warn("Failed to prune git worktrees.")
+		}
+	}()
+
+	bootstrapGOROOT, err := bootstrap(tmp)
+	if err != nil {
+		return err
 	}
 
-	if !version.IsTip() {
+	if version.isRelease() {
 		// write VERSION file
 		versionFile := filepath.Join(tmp, "VERSION")
 		err := os.WriteFile(versionFile, []byte(version.String()), 0o644)
@@ -143,12 +288,15 @@ func buildGo(log logrus.FieldLogger, buildDir, repo string, version *GoVersion,
 		}
 	}
 
-	if err := os.RemoveAll(filepath.Join(tmp, "go", ".git")); err != nil {
-		return err
-	}
+	return makeGo(ctx, log, tmp, bootstrapGOROOT, step)
+}
 
+// makeGo runs make.bash (make.bat on Windows) in the source tree at goroot
+// using the bootstrap toolchain at bootstrapGOROOT, if not empty. The build
+// steps are passed to step.
+func makeGo(ctx context.Context, log logrus.FieldLogger, goroot, bootstrapGOROOT string, step func(string)) error {
 	log.Println("build")
-	srcDir := filepath.Join(tmp, "src")
+	srcDir := filepath.Join(goroot, "src")
 
 	var cmd *command
 	if runtime.GOOS == "windows" {
@@ -156,9 +304,11 @@ func buildGo(log logrus.FieldLogger, buildDir, repo string, version *GoVersion,
 	} else {
 		cmd = makeCommand("bash", "make.bash")
 	}
+	// Stop the compilers and tools started by make.bash along with it.
+	cmd.ProcessGroup = true
 
-	cmd.Env = []string{
-		"GOROOT_BOOTSTRAP=" + bootstrap,
+	if bootstrapGOROOT != "" {
+		cmd.Env = append(cmd.Env, "GOROOT_BOOTSTRAP="+bootstrapGOROOT)
 	}
 
 	// Don't look for go.mod when building bootstrapping older pre-module
@@ -167,43 +317,57 @@ func buildGo(log logrus.FieldLogger, buildDir, repo string, version *GoVersion,
 		cmd.Env = append(cmd.Env, "GO111MODULE=off")
 	}
 
-	return cmd.WithDir(srcDir).WithLogger(log).Exec()
+	// Report the "Building Go toolchain1 ..." style lines printed by make.bash
+	// as build steps.
+	reportSteps := func(logFn func(string)) func(string) {
+		return func(line string) {
+			logFn(line)
+			if strings.HasPrefix(line, "Building ") {
+				step(strings.TrimSuffix(line, "."))
+			}
+		}
+	}
+	cmd.Stdout = reportSteps(infoOutLog(log))
+	cmd.Stderr = reportSteps(errOutLog(log))
+
+	step("Running " + cmd.Args[len(cmd.Args)-1])
+	return cmd.WithDir(srcDir).Exec(ctx)
 }
 
-func (m *Manager) hasSrcVersion(version *GoVersion) (bool, error) {
-	log := m.Logger
-	localGoSrc := filepath.Join(m.cacheDir, "go")
+func (m *Manager) hasSrcVersion(ctx context.Context, version *GoVersion) (bool, error) {
+	log := m.logger(ctx)
+	localGoSrc := m.srcCacheDir()
 
 	tag := fmt.Sprintf("go%s", version)
 	log.Println("check version tag")
 	hasTag := false
-	err := gitListTags(log, localGoSrc, func(t string) { hasTag = hasTag || t == tag })
+	err := gitListTags(ctx, log, localGoSrc, func(t string) { hasTag = hasTag || t == tag })
 	return hasTag, err
 }
 
-func (m *Manager) ensureSrcVersionAvail(version *GoVersion) error {
-	has, err := m.hasSrcVersion(version)
+func (m *Manager) ensureSrcVersionAvail(ctx context.Context, version *GoVersion) error {
+	has, err := m.hasSrcVersion(ctx, version)
 	if err != nil {
 		return err
 	}
 
-	if !has {
-		if err := m.updateSrcCache(); err != nil {
+	if !has && !m.Offline {
+		if err := m.updateSrcCache(ctx); err != nil {
 			return err
 		}
-		has, err = m.hasSrcVersion(version)
+		has, err = m.hasSrcVersion(ctx, version)
 		if err != nil {
 			return err
 		}
 	}
 	if !has {
-		return fmt.Errorf("unknown version %s", version)
+		return fmt.Errorf("unknown version %s: %w", version, common.ErrNotFound)
 	}
 	return nil
 }
 
-func (m *Manager) tryRefreshSrcCache() (bool, error) {
-	log := m.Logger
+func (m *Manager) tryRefreshSrcCache(ctx context.Context) (bool, error) {
+	log := m.logger(ctx)
 
 	localGoSrc := m.srcCacheDir()
 	exists, err := existsDir(localGoSrc)
@@ -212,12 +376,18 @@ func (m *Manager) tryRefreshSrcCache() (bool, error) {
 	}
 	if !exists {
 		log.Println("Go cache not found")
-		err := m.updateSrcCache()
+		err := m.updateSrcCache(ctx)
 		return err == nil, err
 	}
 
+	if m.Offline {
+		log.Println("Offline mode, skipping source cache refresh")
+		return false, nil
+	}
+
+	// A source cache seeded offline was never updated.
 	info := srcCacheInfo{}
-	if err := readJSONFile(filepath.Join(m.cacheDir, "go.meta"), &info); err != nil {
+	if err := readJSONFile(filepath.Join(m.cacheDir, "go.meta"), &info); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return false, err
 	}
 
@@ -233,12 +403,12 @@ func (m *Manager) tryRefreshSrcCache() (bool, error) {
 	}
 
 	log.Println("Fetch updates")
-	if err := m.updateSrcCache(); err != nil {
+	if err := m.updateSrcCache(ctx); err != nil {
 		return false, err // update cache failed
 	}
 
 	// check for updates ;)
-	cTS, err := gitLastCommitTimestamp(log, m.srcCacheDir())
+	cTS, err := gitLastCommitTimestamp(ctx, log, m.srcCacheDir())
 	if err != nil {
 		return false, err
 	}
@@ -257,7 +427,13 @@ func (m *Manager) tryRefreshSrcCache() (bool, error) {
 }
 
 func (m *Manager) AvailableSource() ([]*GoVersion, error) {
-	if updates, err := m.tryRefreshSrcCache(); err != nil {
+	return m.AvailableSourceContext(context.Background())
+}
+
+// AvailableSourceContext is like AvailableSource but stops the git commands
+// when ctx is done.
+func (m *Manager) AvailableSourceContext(ctx context.Context) ([]*GoVersion, error) {
+	if updates, err := m.tryRefreshSrcCache(ctx); err != nil {
 		return nil, fmt.Errorf("failed to refresh source cache: %w", err)
 	} else if updates {
 		log.Println("Source cache was updated.")
@@ -265,7 +441,7 @@ func (m *Manager) AvailableSource() ([]*GoVersion, error) {
 
 	localGoSrc := m.srcCacheDir()
 	var versions []*GoVersion
-	err := gitListTags(m.Logger, localGoSrc, func(tag string) {
+	err := gitListTags(ctx, m.logger(ctx), localGoSrc, func(tag string) {
 		if !strings.HasPrefix(tag, "go") {
 			return
 		}
@@ -287,8 +463,13 @@ func (m *Manager) AvailableSource() ([]*GoVersion, error) {
 	return versions, err
 }
 
-func gitClone(logger logrus.FieldLogger, to, url string, bare bool) error {
+func gitClone(ctx context.Context, logger logrus.FieldLogger, to, url string, bare bool, filter string) error {
 	tmpDir := to + ".tmp"
+	// Remove a directory left behind by a process that was killed. Callers
+	// hold the lock for to so no other process is using it.
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return err
+	}
 	if err := os.Mkdir(tmpDir, 0o755); err != nil {
 		return err
 	}
@@ -298,25 +479,70 @@ func gitClone(logger logrus.FieldLogger, to, url string, bare bool) error {
 	if bare {
 		args = append(args, "--bare")
 	}
+	if filter != "" {
+		args = append(args, "--filter="+filter)
+	}
 	args = append(args, url, tmpDir)
 
 	logger.Println("git clone:")
 	cmd := makeCommand("git", args...).WithLogger(logger)
-	if err := cmd.Exec(); err != nil {
+	if err := cmd.Exec(ctx); err != nil {
+		return err
+	}
+
+	if bare {
+		// Bare clones have no fetch refspec. Fetch branches directly into
+		// refs/heads so that they can be updated with gitFetchBranches.
+		logger.Println("git config:")
+		cmd := makeCommand("git", "config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*")
+		if err := cmd.WithDir(tmpDir).WithLogger(logger).Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Move into the final location.
+	return common.Rename(tmpDir, to)
+}
+
+// gitCloneLegacy creates a bare clone at to from a full clone of url made by
+// older gvm versions. The remote-tracking branches of the full clone become
+// the branches of the bare clone, which fetches from url afterwards.
+func gitCloneLegacy(ctx context.Context, logger logrus.FieldLogger, to, legacy, url string) error {
+	tmpDir := to + ".tmp"
+	// Remove a directory left behind by a process that was killed. Callers
+	// hold the lock for to so no other process is using it.
+	if err := os.RemoveAll(tmpDir); err != nil {
 		return err
 	}
+	defer os.RemoveAll(tmpDir)
+
+	logger.Println("git clone:")
+	if err := makeCommand("git", "clone", "--bare", legacy, tmpDir).WithLogger(logger).Exec(ctx); err != nil {
+		return err
+	}
+
+	for _, args := range [][]string{
+		{"fetch", legacy, "+refs/remotes/origin/*:refs/heads/*", "^refs/remotes/origin/HEAD"},
+		{"config", "remote.origin.url", url},
+		{"config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"},
+	} {
+		logger.Printf("git %v:", args[0])
+		if err := makeCommand("git", args...).WithDir(tmpDir).WithLogger(logger).Exec(ctx); err != nil {
+			return err
+		}
+	}
 
 	// Move into the final location.
 	return common.Rename(tmpDir, to)
 }
 
-func gitLastCommitTimestamp(logger logrus.FieldLogger, path string) (time.Time, error) {
+func gitLastCommitTimestamp(ctx context.Context, logger logrus.FieldLogger, path string) (time.Time, error) {
 	var tsLine string
 
 	logger.Println("git log:")
 	cmd := makeCommand("git", "log", "-n", "1", "--pretty=format:%ct")
 	cmd.Stdout = func(l string) { tsLine = l }
-	err := cmd.WithDir(path).WithLogger(logger).Exec()
+	err := cmd.WithDir(path).WithLogger(logger).Exec(ctx)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -328,19 +554,31 @@ func gitLastCommitTimestamp(logger logrus.FieldLogger, path string) (time.Time,
 	return time.Unix(i, 0), nil
 }
 
-func gitPull(logger logrus.FieldLogger, path string) error {
-	logger.Println("git pull:")
-	return makeCommand("git", "pull").WithDir(path).WithLogger(logger).Exec()
+// gitFetchBranches updates the branches and tags of a bare clone.
+func gitFetchBranches(ctx context.Context, logger logrus.FieldLogger, path string) error {
+	logger.Println("git fetch:")
+	return makeCommand("git", "fetch", "--prune", "--tags", "origin").WithDir(path).WithLogger(logger).Exec(ctx)
+}
+
+// gitWorktreeAdd checks out the tag of the repository at path into a new
+// worktree at dir with a detached HEAD. env is added to the environment of git.
+func gitWorktreeAdd(ctx context.Context, logger logrus.FieldLogger, path, dir, tag string, env []string) error {
+	logger.Println("git worktree add:")
+	cmd := makeCommand("git", "worktree", "add", "--detach", dir, tag)
+	cmd.Env = env
+	return cmd.WithDir(path).WithLogger(logger).Exec(ctx)
 }
 
-func gitCheckout(logger logrus.FieldLogger, path, tag string) error {
-	logger.Println("git checkout:")
-	return makeCommand("git", "checkout", tag).WithDir(path).WithLogger(logger).Exec()
+// gitWorktreePrune removes the administrative files of worktrees whose
+// directory no longer exists.
+func gitWorktreePrune(ctx context.Context, logger logrus.FieldLogger, path string) error {
+	logger.Println("git worktree prune:")
+	return makeCommand("git", "worktree", "prune").WithDir(path).WithLogger(logger).Exec(ctx)
 }
 
-func gitListTags(logger logrus.FieldLogger, path string, fn func(string)) error {
+func gitListTags(ctx context.Context, logger logrus.FieldLogger, path string, fn func(string)) error {
 	logger.Println("git tag:")
 	cmd := makeCommand("git", "tag").WithDir(path).WithLogger(logger)
 	cmd.Stdout = fn
-	return cmd.Exec()
+	return cmd.Exec(ctx)
 }
@@ -0,0 +1,162 @@
+package gvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// storageHomes returns the mirrors listed in GoStorageHome in the configured
+// order.
+func (m *Manager) storageHomes() []string {
+	return splitStorageHome(m.GoStorageHome)
+}
+
+// splitStorageHome splits the comma-separated list of mirrors. Mirrors cannot
+// contain a literal comma, URLs must escape it as %2C.
+func splitStorageHome(s string) []string {
+	var homes []string
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			homes = append(homes, h)
+		}
+	}
+	return homes
+}
+
+// normalizeStorageHome converts local directories in the comma-separated list
+// of mirrors to file:// URLs.
+func normalizeStorageHome(s string) (string, error) {
+	homes := splitStorageHome(s)
+	for i, h := range homes {
+		if strings.Contains(h, "://") {
+			continue
+		}
+		// Treat values without a scheme as local directories.
+		u, err := common.FileURL(h)
+		if err != nil {
+			return "", err
+		}
+		homes[i] = u
+	}
+	return strings.Join(homes, ","), nil
+}
+
+// mirrorHealth tracks the mirrors that failed during the life of the process.
+// Mirrors that failed are tried after the healthy ones.
+type mirrorHealth struct {
+	mu     sync.Mutex
+	failed map[string]time.Time // Time of the last failure of each mirror.
+}
+
+// order returns the healthy mirrors in their configured order followed by the
+// failed mirrors, least recently failed first.
+func (h *mirrorHealth) order(mirrors []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ordered := make([]string, len(mirrors))
+	copy(ordered, mirrors)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		fi, fj := h.failed[ordered[i]], h.failed[ordered[j]]
+		return fi.Before(fj)
+	})
+	return ordered
+}
+
+func (h *mirrorHealth) failure(mirror string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failed == nil {
+		h.failed = map[string]time.Time{}
+	}
+	h.failed[mirror] = time.Now()
+}
+
+func (h *mirrorHealth) success(mirror string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failed, mirror)
+}
+
+// eachMirror calls fn with the base URL of each mirror until one succeeds.
+// It fails over to the next mirror on connection errors, 5xx responses, and
+// other errors that another mirror may not have. It stops at the first mirror
+// that reports common.ErrNotFound or a client error so that a missing file
+// is treated the same as with a single mirror.
+func (m *Manager) eachMirror(ctx context.Context, fn func(base string) error) error {
+	mirrors := m.mirrors.order(m.storageHomes())
+	if len(mirrors) == 0 {
+		return errors.New("no Go storage home configured")
+	}
+
+	var err error
+	for i, base := range mirrors {
+		if err = fn(base); err == nil {
+			m.mirrors.success(base)
+			return nil
+		}
+		if ctx.Err() != nil || !failover(err) {
+			return err
+		}
+		log := m.Logger.WithError(err).WithField("mirror", base)
+		if errors.Is(err, ErrOffline) {
+			log.Debug("Mirror not available offline.")
+			continue
+		}
+		m.mirrors.failure(base)
+		if i < len(mirrors)-1 {
+			log.Warn("Mirror failed, trying next mirror.")
+		}
+	}
+	return err
+}
+
+// failover returns true if a request that failed with err should be tried on
+// another mirror.
+func failover(err error) bool {
+	if errors.Is(err, common.ErrNotFound) {
+		return false
+	}
+	var statusErr *common.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Retryable()
+	}
+	return true
+}
+
+// downloadArchive downloads the archive described by file from the first
+// available mirror into dir and verifies it. It returns the path to the file.
+func (m *Manager) downloadArchive(ctx context.Context, file *GoFile, dir string) (string, error) {
+	var path string
+	err := m.eachMirror(ctx, func(base string) error {
+		if _, local := common.FileURLPath(base); m.Offline && !local {
+			return fmt.Errorf("archive %v is not cached: %w", file.Filename, ErrOffline)
+		}
+
+		goURL := constructDownloadURL(base, file.Filename)
+		downloaded, err := m.downloadFile(ctx, goURL, dir, file.Size)
+		if err != nil {
+			return fmt.Errorf("failed downloading from %v: %w", goURL, err)
+		}
+
+		// Verify the archive against the checksum published in the release
+		// index before using it.
+		if err := common.VerifyFile(downloaded, file.Size, file.SHA256); err != nil {
+			if removeErr := os.Remove(downloaded); removeErr != nil {
+				m.Logger.WithError(removeErr).Warnf("Failed to remove %v after verification failure.", downloaded)
+			}
+			return err
+		}
+		path = downloaded
+		return nil
+	})
+	return path, err
+}
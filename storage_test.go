@@ -0,0 +1,98 @@
+package gvm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// newTestMirrorServer serves a mirror directory like the go.dev downloads API.
+func newTestMirrorServer(t *testing.T, mirror string) *httptest.Server {
+	t.Helper()
+	files := http.FileServer(http.Dir(mirror))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.ServeFile(w, r, filepath.Join(mirror, localIndexFile))
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMirrorFailover(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.22.5")
+	good := newTestMirrorServer(t, mirror)
+
+	var badRequests atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badRequests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+
+	m := newTestManager(t, bad.URL+", "+good.URL)
+	m.Retry.MaxAttempts = 1
+
+	_, err := m.Install(MustParseVersion("1.22.5"))
+	require.NoError(t, err)
+	assert.DirExists(t, filepath.Join(m.VersionGoROOT(MustParseVersion("1.22.5")), "bin"))
+
+	// The failed mirror is only tried once because it is tried last after it
+	// failed.
+	assert.EqualValues(t, 1, badRequests.Load())
+	assert.Equal(t, []string{good.URL, bad.URL}, m.mirrors.order(m.storageHomes()))
+}
+
+func TestMirrorFailoverNotFound(t *testing.T) {
+	mirror := t.TempDir()
+	writeTestMirror(t, mirror, "1.22.5")
+	good := newTestMirrorServer(t, mirror)
+
+	// A mirror that has the index but not the archives.
+	index := filepath.Join(mirror, localIndexFile)
+	partial := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.ServeFile(w, r, index)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer partial.Close()
+
+	m := newTestManager(t, partial.URL+","+good.URL)
+	_, err := m.fetchBinary(t.Context(), MustParseVersion("1.22.5"))
+	assert.ErrorIs(t, err, common.ErrNotFound)
+}
+
+func TestStorageHomeEscapedComma(t *testing.T) {
+	mirror := filepath.Join(t.TempDir(), "go,mirror")
+	require.NoError(t, os.Mkdir(mirror, 0o755))
+	writeTestMirror(t, mirror, "1.22.5")
+
+	m := newTestManager(t, strings.ReplaceAll(mustFileURL(t, mirror), ",", "%2C"))
+	m.Offline = true
+	require.Len(t, m.storageHomes(), 1)
+
+	dir, err := m.Install(MustParseVersion("1.22.5"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "bin", "go"))
+}
+
+func TestNormalizeStorageHome(t *testing.T) {
+	dir := t.TempDir()
+	s, err := normalizeStorageHome(" https://a.example.com/dl ,," + dir)
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example.com/dl,"+mustFileURL(t, dir), s)
+}
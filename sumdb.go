@@ -0,0 +1,207 @@
+package gvm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+
+	"github.com/andrewkroh/gvm/common"
+)
+
+// defaultGOSUMDB is the checksum database used when GOSUMDB is not set.
+const defaultGOSUMDB = "sum.golang.org"
+
+// knownSumDBKeys are the verifier keys of well known checksum databases.
+var knownSumDBKeys = map[string]string{
+	"sum.golang.org": "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ufvzbu0huvlrAiZ",
+}
+
+// sumDBOps implements sumdb.ClientOps for the Manager. The latest signed tree
+// head is kept under the gvm home directory so that every tree head seen is
+// checked for consistency with the previous one, across runs. Tiles and
+// lookups are cached in the cache directory.
+type sumDBOps struct {
+	ctx    context.Context
+	m      *Manager
+	name   string // Name of the database (e.g. sum.golang.org).
+	vkey   string // Verifier key of the database.
+	direct string // URL of the database itself.
+
+	once    sync.Once
+	base    string // URL that requests are sent to, set by once.
+	baseErr error
+
+	mu          sync.Mutex
+	securityErr string
+}
+
+// newSumDBOps returns the client operations for the checksum database
+// configured by the gosumdb value (the GOSUMDB environment variable syntax).
+func (m *Manager) newSumDBOps(ctx context.Context, gosumdb string) (*sumDBOps, error) {
+	if gosumdb == "" {
+		gosumdb = defaultGOSUMDB
+	}
+
+	fields := strings.Fields(gosumdb)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid GOSUMDB %q: want \"key\" or \"key url\"", gosumdb)
+	}
+	vkey := fields[0]
+	if known, found := knownSumDBKeys[vkey]; found {
+		vkey = known
+	}
+	verifier, err := note.NewVerifier(vkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GOSUMDB %q: %w", gosumdb, err)
+	}
+
+	direct := "https://" + verifier.Name()
+	if len(fields) > 1 {
+		direct = strings.TrimSuffix(fields[1], "/")
+		if !strings.Contains(direct, "://") {
+			direct = "https://" + direct
+		}
+	}
+	return &sumDBOps{ctx: ctx, m: m, name: verifier.Name(), vkey: vkey, direct: direct}, nil
+}
+
+// ReadRemote fetches the path from the checksum database, through the first
+// module proxy that supports it.
+func (o *sumDBOps) ReadRemote(path string) ([]byte, error) {
+	o.once.Do(func() { o.base, o.baseErr = o.resolveBase() })
+	if o.baseErr != nil {
+		return nil, o.baseErr
+	}
+
+	resp, err := o.m.getURL(o.ctx, o.base+path)
+	if err != nil {
+		return nil, fmt.Errorf("checksum database request for %v failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// resolveBase returns the URL to send checksum database requests to. Like
+// cmd/go, it uses the first proxy that answers <proxy>/sumdb/<name>/supported
+// and falls back to the database itself.
+func (o *sumDBOps) resolveBase() (string, error) {
+	proxies, err := o.m.goproxyList()
+	if err != nil {
+		return "", err
+	}
+	for _, proxy := range proxies {
+		base := proxy.url + "/sumdb/" + o.name
+		resp, err := o.m.getURL(o.ctx, base+"/supported")
+		switch {
+		case err == nil:
+			resp.Body.Close()
+			return base, nil
+		case errors.Is(err, common.ErrNotFound):
+			continue
+		case o.ctx.Err() != nil:
+			return "", o.ctx.Err()
+		case proxy.fallbackOnError:
+			continue
+		default:
+			return "", fmt.Errorf("failed to check checksum database support of %v: %w", proxy.url, err)
+		}
+	}
+	return o.direct, nil
+}
+
+// configFile returns the path of a configuration file of the client.
+func (o *sumDBOps) configFile(file string) string {
+	return filepath.Join(o.m.Home, "sumdb", filepath.FromSlash(file))
+}
+
+// ReadConfig returns the verifier key or the last tree head seen.
+func (o *sumDBOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.vkey), nil
+	}
+	data, err := os.ReadFile(o.configFile(file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// WriteConfig replaces the configuration file if it still contains old.
+func (o *sumDBOps) WriteConfig(file string, old, new []byte) error {
+	path := o.configFile(file)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return o.m.withLock(o.ctx, "sumdb", func() error {
+		cur, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if !bytes.Equal(cur, old) {
+			return sumdb.ErrWriteConflict
+		}
+		return writeFileAtomic(path, new)
+	})
+}
+
+// cacheFile returns the path of a cached tile or lookup.
+func (o *sumDBOps) cacheFile(file string) string {
+	return filepath.Join(o.m.cacheDir, "sumdb", filepath.FromSlash(file))
+}
+
+// ReadCache returns a cached tile or lookup.
+func (o *sumDBOps) ReadCache(file string) ([]byte, error) {
+	return os.ReadFile(o.cacheFile(file))
+}
+
+// WriteCache caches a tile or lookup. Failures only cost a refetch.
+func (o *sumDBOps) WriteCache(file string, data []byte) {
+	path := o.cacheFile(file)
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+	if err != nil {
+		o.m.Logger.WithError(err).Debugf("Failed to cache checksum database file %v.", file)
+	}
+}
+
+// Log logs a debug message from the client.
+func (o *sumDBOps) Log(msg string) {
+	o.m.Logger.Debug(msg)
+}
+
+// SecurityError records evidence that the checksum database misbehaved. The
+// client returns sumdb.ErrSecurity from all later lookups.
+func (o *sumDBOps) SecurityError(msg string) {
+	o.m.Logger.Error(msg)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.securityErr == "" {
+		o.securityErr = msg
+	}
+}
+
+// lookup returns the go.sum lines for the module version.
+func (o *sumDBOps) lookup(path, vers string) ([]string, error) {
+	lines, err := sumdb.NewClient(o).Lookup(path, vers)
+	if errors.Is(err, sumdb.ErrSecurity) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.securityErr != "" {
+			return nil, fmt.Errorf("%w\n%v", err, o.securityErr)
+		}
+	}
+	return lines, err
+}
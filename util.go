@@ -1,8 +1,10 @@
 package gvm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -25,19 +27,61 @@ func homeDir() (string, error) {
 	return homeDir, nil
 }
 
-func extractTo(to, file string) (string, error) {
+// httpClient returns the HTTPClient or, if it is nil, a client using the
+// HTTPTimeout.
+func (m *Manager) httpClient() *http.Client {
+	if m.HTTPClient != nil {
+		return m.HTTPClient
+	}
+	return &http.Client{Timeout: m.HTTPTimeout}
+}
+
+// downloadFile downloads the URL into dir using the Manager's download
+// settings and returns the path to the file. size is the expected size of the
+// file used for progress reporting, or 0 if unknown.
+func (m *Manager) downloadFile(ctx context.Context, url, dir string, size int64) (string, error) {
+	return common.DownloadFileWithOptions(ctx, url, dir, common.DownloadOptions{
+		Client:      m.httpClient(),
+		Retry:       m.Retry,
+		Connections: m.DownloadConnections,
+		Progress:    m.downloadProgress(url, size),
+	})
+}
+
+// extractTo extracts the go directory of a binary distribution archive, or the
+// module directory of a golang.org/toolchain module zip, to the GOROOT at to.
+func (m *Manager) extractTo(ctx context.Context, to, file string) (string, error) {
 	tmpDir := to + ".tmp"
+	// Remove a directory left behind by a process that was killed. Callers
+	// hold the lock for to so no other process is using it.
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return "", err
+	}
 	if err := os.Mkdir(tmpDir, 0o755); err != nil {
 		return "", err
 	}
 	defer os.RemoveAll(tmpDir)
 
-	if err := common.Extract(file, tmpDir); err != nil {
+	if err := common.ExtractWithProgress(ctx, file, tmpDir, m.extractProgress(file)); err != nil {
 		return "", err
 	}
 
+	goroot := filepath.Join(tmpDir, "go")
+	if _, err := os.Stat(goroot); os.IsNotExist(err) {
+		// Module zips contain a single directory named after the module
+		// version and do not record file modes.
+		dirs, _ := filepath.Glob(filepath.Join(tmpDir, filepath.FromSlash(toolchainModule)+"@*"))
+		if len(dirs) != 1 {
+			return "", fmt.Errorf("archive %v contains no Go distribution", file)
+		}
+		goroot = dirs[0]
+		if err := markToolchainExecutables(goroot); err != nil {
+			return "", err
+		}
+	}
+
 	// Move into the final location.
-	if err := common.Rename(filepath.Join(tmpDir, "go"), to); err != nil {
+	if err := common.Rename(goroot, to); err != nil {
 		return "", err
 	}
 	return to, nil
@@ -70,3 +114,22 @@ func readJSONFile(filename string, to interface{}) error {
 	}
 	return json.Unmarshal(contents, to)
 }
+
+// writeFileAtomic writes data to a temporary file and renames it to filename
+// so that readers never observe a partially written file.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -2,14 +2,17 @@ package gvm
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 
 	version "github.com/hashicorp/go-version"
 )
 
+// GoVersion is a Go release, tip, or a named version built from a git ref.
 type GoVersion struct {
 	in      string
-	version *version.Version
+	version *version.Version // nil for tip and named versions.
 }
 
 // MustParseVersion parses the given Go version to return a GoVersion.
@@ -36,8 +39,26 @@ func ParseVersion(in string) (*GoVersion, error) {
 	return &GoVersion{in: in, version: v}, nil
 }
 
+// nameRegexp matches the names of named versions.
+var nameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._+-]*$`)
+
+// NamedVersion returns a named version for a toolchain that is not a Go
+// release, such as a build of a git branch or commit. Names must start with a
+// letter and may contain letters, digits, '.', '_', '+', and '-'. Names that
+// could be confused with a version or version specifier are rejected.
+func NamedVersion(name string) (*GoVersion, error) {
+	if !nameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid version name %q", name)
+	}
+	trimmed := strings.TrimPrefix(name, "go")
+	if _, err := version.NewVersion(trimmed); err == nil || name == "tip" || isSymbolicSpec(trimmed) {
+		return nil, fmt.Errorf("invalid version name %q: it is reserved for Go versions", name)
+	}
+	return &GoVersion{in: name}, nil
+}
+
 func (v *GoVersion) String() string {
-	if v.in == "tip" {
+	if v.version == nil {
 		return v.in
 	}
 
@@ -56,32 +77,39 @@ func (v *GoVersion) String() string {
 	return v.version.String()
 }
 
+// LessThan orders releases before named versions, which are ordered by name,
+// and tip last.
 func (v *GoVersion) LessThan(v2 *GoVersion) bool {
-	if v.in == "tip" {
+	switch {
+	case v.isRelease() && v2.isRelease():
+		return v.version.LessThan(v2.version)
+	case v.isRelease() != v2.isRelease():
+		return v.isRelease()
+	case v.IsTip():
 		return false
-	}
-	if v2.in == "tip" {
+	case v2.IsTip():
 		return true
+	default:
+		return v.in < v2.in
 	}
-	return v.version.LessThan(v2.version)
 }
 
 func (v *GoVersion) Stable() bool {
-	if v.in == "tip" {
+	if !v.isRelease() {
 		return false
 	}
 	return v.version.Prerelease() == ""
 }
 
 func (v *GoVersion) Prerelease() bool {
-	if v.in == "tip" {
+	if !v.isRelease() {
 		return false
 	}
 	return v.version.Prerelease() != ""
 }
 
 func (v *GoVersion) VendorSupport() (has, experimental bool) {
-	if v.in == "tip" {
+	if !v.isRelease() {
 		return true, false
 	}
 
@@ -93,6 +121,22 @@ func (v *GoVersion) VendorSupport() (has, experimental bool) {
 	return seg[1] >= 5, seg[1] == 5
 }
 
+// segments returns the major and minor version numbers. It must only be
+// called on releases.
+func (v *GoVersion) segments() (major, minor int) {
+	seg := v.version.Segments()
+	return seg[0], seg[1]
+}
+
+// inMinorLine returns true if v is a release of the given minor version.
+func (v *GoVersion) inMinorLine(major, minor int) bool {
+	if !v.isRelease() {
+		return false
+	}
+	vMajor, vMinor := v.segments()
+	return vMajor == major && vMinor == minor
+}
+
 func sortVersions(versions []*GoVersion) {
 	sort.Slice(versions, func(i, j int) bool {
 		return versions[i].LessThan(versions[j])
@@ -102,3 +146,15 @@ func sortVersions(versions []*GoVersion) {
 func (v *GoVersion) IsTip() bool {
 	return v.in == "tip"
 }
+
+// IsNamed returns true if the version is a named version created by
+// NamedVersion.
+func (v *GoVersion) IsNamed() bool {
+	return v.version == nil && v.in != "tip"
+}
+
+// isRelease returns true if the version is a Go release rather than tip or a
+// named version.
+func (v *GoVersion) isRelease() bool {
+	return v.version != nil
+}
@@ -0,0 +1,127 @@
+package gvm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoVersionFile is returned by FindProjectVersion when no file declaring a
+// Go version is found.
+var ErrNoVersionFile = errors.New("no .go-version, go.work, or go.mod file found")
+
+// versionFiles are the files that declare a Go version, in order of
+// precedence within a single directory.
+var versionFiles = []string{".go-version", "go.work", "go.mod"}
+
+// FindProjectVersion walks up from dir looking for a .go-version, go.work, or
+// go.mod file and returns the version specifier it declares along with the
+// path of the file. As in the go command, a go.work file in any parent
+// directory takes precedence over the nearest go.mod file. For go.work and
+// go.mod files the toolchain directive is preferred over the go directive,
+// and either names an exact version. The returned specifier can be passed to
+// ResolveVersion.
+func FindProjectVersion(dir string) (spec, file string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	names := versionFiles
+	for {
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			found, err := readVersionFile(path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return "", "", err
+			}
+			if found == "" {
+				continue
+			}
+			if name != "go.mod" {
+				return found, path, nil
+			}
+			// Keep looking for a go.work file governing the module.
+			spec, file = found, path
+			names = []string{"go.work"}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			if spec != "" {
+				return spec, file, nil
+			}
+			return "", "", ErrNoVersionFile
+		}
+		dir = parent
+	}
+}
+
+// readVersionFile returns the version declared in the given file. It returns
+// an empty string if the file does not declare a version.
+func readVersionFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	isGoVersion := filepath.Base(path) == ".go-version"
+
+	var goDirective, toolchainDirective string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		if isGoVersion {
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if isGoVersion {
+			return strings.TrimPrefix(line, "go"), nil
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "go":
+			goDirective = fields[1]
+		case "toolchain":
+			// Toolchain names may carry a suffix like go1.21.1+auto.
+			name, _, _ := strings.Cut(fields[1], "+")
+			if name != "default" {
+				toolchainDirective = strings.TrimPrefix(name, "go")
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", fmt.Errorf("failed reading %v: %w", path, err)
+	}
+
+	version := goDirective
+	if toolchainDirective != "" {
+		version = toolchainDirective
+	}
+	// The directives name exact versions, so 1.20 is the release go1.20
+	// rather than the newest 1.20.x.
+	if minorLineRegexp.MatchString(version) {
+		version += ".0"
+	}
+	return version, nil
+}
@@ -0,0 +1,80 @@
+package gvm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindProjectVersion(t *testing.T) {
+	writeFile := func(t *testing.T, path, contents string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+
+	t.Run("go_directive", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "go.mod"), "module example.com/foo\n\ngo 1.22\n")
+		sub := filepath.Join(root, "internal", "pkg")
+		require.NoError(t, os.MkdirAll(sub, 0o755))
+
+		spec, file, err := FindProjectVersion(sub)
+		require.NoError(t, err)
+		assert.Equal(t, "1.22.0", spec)
+		assert.Equal(t, filepath.Join(root, "go.mod"), file)
+	})
+
+	t.Run("toolchain_directive", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "go.mod"), "module example.com/foo\n\ngo 1.22.0 // minimum\n\ntoolchain go1.22.5\n")
+
+		spec, _, err := FindProjectVersion(root)
+		require.NoError(t, err)
+		assert.Equal(t, "1.22.5", spec)
+	})
+
+	t.Run("go_work_preferred", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "go.work"), "go 1.23.1\n\nuse ./foo\n")
+		writeFile(t, filepath.Join(root, "go.mod"), "module example.com/foo\n\ngo 1.22\n")
+
+		spec, _, err := FindProjectVersion(root)
+		require.NoError(t, err)
+		assert.Equal(t, "1.23.1", spec)
+	})
+
+	t.Run("go_version_file", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, ".go-version"), "# pinned\ngo1.21.13\n")
+		writeFile(t, filepath.Join(root, "go.mod"), "module example.com/foo\n\ngo 1.22\n")
+
+		spec, _, err := FindProjectVersion(root)
+		require.NoError(t, err)
+		assert.Equal(t, "1.21.13", spec)
+	})
+
+	t.Run("nearest_wins", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, ".go-version"), "1.21.13\n")
+		writeFile(t, filepath.Join(root, "foo", "go.mod"), "module example.com/foo\n\ngo 1.22\n")
+
+		spec, _, err := FindProjectVersion(filepath.Join(root, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, "1.22.0", spec)
+	})
+
+	t.Run("parent_go_work", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "go.work"), "go 1.23.1\n\nuse ./foo\n")
+		writeFile(t, filepath.Join(root, "foo", "go.mod"), "module example.com/foo\n\ngo 1.22\n")
+
+		spec, file, err := FindProjectVersion(filepath.Join(root, "foo"))
+		require.NoError(t, err)
+		assert.Equal(t, "1.23.1", spec)
+		assert.Equal(t, filepath.Join(root, "go.work"), file)
+	})
+}